@@ -0,0 +1,91 @@
+// Command bloomfilter builds the breached password bloom filter read by PASSWORD_BREACHED_CORPUS
+// from a file of SHA-1 hashes, one per line and optionally followed by ":<count>",
+// such as the hash list published by Have I Been Pwned.
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/passpolicy"
+)
+
+func main() {
+	in := flag.String("in", "", "file of SHA-1 hashes")
+	out := flag.String("out", "breached.bloom", "bloom filter to write")
+	falsePositiveRate := flag.Float64("fp", 0.001, "share of other passwords reported as leaked")
+	minCount := flag.Int("min-count", 0, "skip hashes seen fewer times than this")
+	flag.Parse()
+
+	if *in == "" {
+		log.Fatal("-in is required")
+	}
+
+	// The first pass sizes the filter, the second fills it
+	var n uint64
+	err := eachHash(*in, *minCount, func([sha1.Size]byte) { n++ })
+	if err != nil {
+		log.Fatalf("Failed to read hashes: %v", err)
+	}
+
+	filter, err := passpolicy.NewBloomFilter(n, *falsePositiveRate)
+	if err != nil {
+		log.Fatalf("Failed to create bloom filter: %v", err)
+	}
+	if err := eachHash(*in, *minCount, filter.AddHash); err != nil {
+		log.Fatalf("Failed to read hashes: %v", err)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *out, err)
+	}
+	writer := bufio.NewWriter(file)
+	if _, err := filter.WriteTo(writer); err != nil {
+		log.Fatalf("Failed to write bloom filter: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("Failed to write bloom filter: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("Failed to write bloom filter: %v", err)
+	}
+
+	log.Printf("Wrote %d hashes to %s", n, *out)
+}
+
+// eachHash calls fn for every hash in the file seen at least minCount times; malformed lines are skipped
+func eachHash(path string, minCount int, fn func([sha1.Size]byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		digest, count, hasCount := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hasCount && minCount > 0 {
+			if seen, err := strconv.Atoi(count); err != nil || seen < minCount {
+				continue
+			}
+		}
+
+		decoded, err := hex.DecodeString(digest)
+		if err != nil || len(decoded) != sha1.Size {
+			continue
+		}
+
+		var sum [sha1.Size]byte
+		copy(sum[:], decoded)
+		fn(sum)
+	}
+
+	return scanner.Err()
+}
@@ -7,12 +7,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/diplom/auth-service/internal/grpc"
 	"github.com/diplom/auth-service/internal/handlers"
+	"github.com/diplom/auth-service/internal/mail"
+	"github.com/diplom/auth-service/internal/passhash"
+	"github.com/diplom/auth-service/internal/passpolicy"
+	"github.com/diplom/auth-service/internal/ratelimit"
 	"github.com/diplom/auth-service/internal/repository"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/diplom/auth-service/internal/utils"
 	"github.com/gin-gonic/gin"
 	ggrpc "google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -29,10 +36,12 @@ func main() {
 	}
 
 	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Println("JWT_SECRET environment variable not set, using default (for development only)")
-		jwtSecret = "your_secret_key_here"
-		os.Setenv("JWT_SECRET", jwtSecret)
+	if jwtSecret == "" || jwtSecret == "your_secret_key_here" {
+		log.Fatal("JWT_SECRET environment variable must be set to a random secret")
+	}
+
+	if os.Getenv("OIDC_ISSUER") == "" {
+		log.Printf("OIDC_ISSUER environment variable not set, using default %s", utils.Issuer())
 	}
 
 	// Connect to the database
@@ -48,13 +57,132 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	// Create repository and handler
+	// Create repositories and handlers
 	userRepo := repository.NewUserRepository(db)
-	authHandler := handlers.NewAuthHandler(userRepo)
+	tokenRepo := repository.NewTokenRepository(db)
+	keyRepo := repository.NewKeyRepository(db)
+	clientRepo := repository.NewClientRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	attemptRepo := repository.NewLoginAttemptRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure mail transport: %v", err)
+	}
+	hasher, err := passhash.NewHasherFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+	log.Printf("Hashing new passwords with %s", hasher.Algorithm())
+	passwordPolicy, err := passpolicy.PolicyFromEnv(hasher.Algorithm())
+	if err != nil {
+		log.Fatalf("Failed to configure password policy: %v", err)
+	}
+	authService := service.NewAuthService(userRepo, tokenRepo, clientRepo, mfaRepo, attemptRepo, auditRepo, roleRepo, mailer, hasher, passwordPolicy)
+	clientService := service.NewClientService(clientRepo)
+	authHandler := handlers.NewAuthHandler(authService)
+	oauthHandler := handlers.NewOAuthHandler(authService, clientService)
+	clientHandler := handlers.NewClientHandler(clientService)
+	userHandler := handlers.NewUserHandler(authService)
+	auditHandler := handlers.NewAuditHandler(authService)
+	roleHandler := handlers.NewRoleHandler(authService)
+
+	// Rate limit buckets live in memory unless replicas have to share them
+	var rateLimitStore ratelimit.Store
+	var rateLimitRepo *repository.RateLimitRepository
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitRepo = repository.NewRateLimitRepository(db)
+		rateLimitStore = rateLimitRepo
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_STORE %q", store)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore)
+
+	// Configured keys sign tokens until a generated key is promoted
+	staticKeys := []*utils.SigningKey{}
+	if keyFile := os.Getenv("JWT_PRIVATE_KEY_FILE"); keyFile != "" {
+		key, err := utils.LoadSigningKey(keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		staticKeys = append(staticKeys, key)
+
+		// HS256 tokens issued before the key file was configured stay valid until they expire
+		legacyKey := utils.NewHMACSigningKey([]byte(jwtSecret))
+		retiredAt := time.Now()
+		legacyKey.Status = utils.KeyStatusRetired
+		legacyKey.RetiredAt = &retiredAt
+		staticKeys = append(staticKeys, legacyKey)
+	} else {
+		log.Println("JWT_PRIVATE_KEY_FILE environment variable not set, signing access tokens with HS256")
+		staticKeys = append(staticKeys, utils.NewHMACSigningKey([]byte(jwtSecret)))
+	}
+
+	keyManager := utils.NewKeyManager(keyRepo, staticKeys...)
+	if err := keyManager.Reload(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	utils.SetKeyManager(keyManager)
+	activeKey := keyManager.ActiveKey()
+	log.Printf("Signing access tokens with %s key %s", activeKey.Method.Alg(), activeKey.ID)
+	if activeKey.IsSymmetric() {
+		log.Println("Active signing key is symmetric, OpenID Connect clients will not be able to verify ID tokens")
+	}
+	keyHandler := handlers.NewKeyHandler(keyManager)
+
+	// Pick up keys rotated by other replicas
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := keyManager.Reload(); err != nil {
+				log.Printf("Error reloading signing keys: %v", err)
+			}
+		}
+	}()
+
+	// Make revoked tokens visible to every token check, including gRPC ValidateToken
+	utils.SetRevocationStore(tokenRepo)
+
+	// Periodically drop revocation records, authorization codes, WebAuthn challenges, reset tokens,
+	// failed login counters and rate limit buckets that have expired anyway, and old login history
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			tokenRepo.PurgeExpiredRevocations()
+			tokenRepo.PurgeExpiredAuthorizationCodes()
+			mfaRepo.PurgeExpiredWebAuthnSessions()
+			tokenRepo.PurgeExpiredPasswordResetTokens()
+			attemptRepo.PurgeStale(utils.LoginLockoutPolicy().Duration)
+			userRepo.PurgeLoginHistory(utils.LoginHistoryRetention())
+			if rateLimitRepo != nil {
+				rateLimitRepo.PurgeIdle()
+			}
+		}
+	}()
 
 	// Setup Gin router
 	router := gin.Default()
-	handlers.SetupRoutes(router, authHandler)
+
+	// Client addresses, used for login lockouts and rate limits, are taken from X-Forwarded-For only behind trusted proxies
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.Use(handlers.GlobalRateLimit(limiter))
+	handlers.SetupRoutes(router, authHandler, limiter)
+	handlers.SetupOAuthRoutes(router, oauthHandler, limiter)
+	handlers.SetupAdminRoutes(router, keyHandler, clientHandler, userHandler, auditHandler, roleHandler)
 
 	// Create HTTP server
 	httpServer := &http.Server{
@@ -77,8 +205,12 @@ func main() {
 			log.Fatalf("Failed to listen on port 50051: %v", err)
 		}
 
-		s := ggrpc.NewServer()
-		grpc.RegisterGRPCServer(s)
+		rateLimiter := grpc.NewRateLimiter(limiter)
+		s := ggrpc.NewServer(
+			ggrpc.ChainUnaryInterceptor(rateLimiter.UnaryInterceptor()),
+			ggrpc.ChainStreamInterceptor(rateLimiter.StreamInterceptor()),
+		)
+		grpc.RegisterGRPCServer(s, authService)
 		reflection.Register(s) // Enable reflection for debugging
 
 		log.Println("Starting gRPC server on :50051")
@@ -0,0 +1,166 @@
+package grpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diplom/auth-service/internal/ratelimit"
+	"github.com/diplom/auth-service/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Rate limit policies of the gRPC API; RATE_LIMIT_<NAME> overrides each of them, "off" disables it
+var (
+	// rpcPolicy applies to every call from one address without a policy of its own
+	rpcPolicy = ratelimit.Policy{Name: "grpc", Limit: 1200, Period: time.Minute}
+	// validatePolicy applies to token validation, which other services call on every request they serve
+	validatePolicy = ratelimit.Policy{Name: "grpc_validate", Limit: 60000, Period: time.Minute}
+)
+
+// emailRequest is implemented by the requests that carry an email address
+type emailRequest interface {
+	GetEmail() string
+}
+
+// rateLimitRule applies a policy to the bucket named by key; calls without a key are not limited by it
+type rateLimitRule struct {
+	policy ratelimit.Policy
+	key    func(ctx context.Context, req interface{}) string
+}
+
+// RateLimiter throttles gRPC calls per method
+type RateLimiter struct {
+	limiter  *ratelimit.Limiter
+	rules    map[string][]rateLimitRule
+	fallback []rateLimitRule
+}
+
+// NewRateLimiter creates a new RateLimiter with the policies of each RPC
+func NewRateLimiter(limiter *ratelimit.Limiter) *RateLimiter {
+	validate := []rateLimitRule{{policy: ratelimit.PolicyFromEnv(validatePolicy), key: byIP}}
+	login := []rateLimitRule{
+		{policy: ratelimit.PolicyFromEnv(ratelimit.LoginIPPolicy), key: byIP},
+		{policy: ratelimit.PolicyFromEnv(ratelimit.LoginEmailPolicy), key: byEmail},
+	}
+
+	return &RateLimiter{
+		limiter: limiter,
+		rules: map[string][]rateLimitRule{
+			"ValidateToken":       validate,
+			"ValidateTokens":      validate,
+			"ValidateTokenStream": validate,
+			"Register":            {{policy: ratelimit.PolicyFromEnv(ratelimit.RegisterPolicy), key: byIP}},
+			"Login":               login,
+			"VerifyMFA":           login[:1],
+			"RefreshToken":        {{policy: ratelimit.PolicyFromEnv(ratelimit.RefreshPolicy), key: byIP}},
+		},
+		fallback: []rateLimitRule{
+			{policy: ratelimit.PolicyFromEnv(rpcPolicy), key: byIP},
+			{policy: ratelimit.PolicyFromEnv(ratelimit.ClientPolicy), key: byClient},
+		},
+	}
+}
+
+// UnaryInterceptor rejects unary calls over their limit with ResourceExhausted
+func (l *RateLimiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		result, limited := l.take(ctx, info.FullMethod, req)
+		if limited {
+			grpc.SetHeader(ctx, rateLimitHeaders(result))
+			if !result.Allowed {
+				return nil, rateLimitError(result)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor rejects streams over their limit with ResourceExhausted; each stream counts once
+func (l *RateLimiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		result, limited := l.take(ss.Context(), info.FullMethod, nil)
+		if limited {
+			ss.SetHeader(rateLimitHeaders(result))
+			if !result.Allowed {
+				return rateLimitError(result)
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// take counts the call against the rules of its method and returns the strictest result
+func (l *RateLimiter) take(ctx context.Context, fullMethod string, req interface{}) (ratelimit.Result, bool) {
+	if l.limiter == nil {
+		return ratelimit.Result{}, false
+	}
+
+	rules, ok := l.rules[fullMethod[strings.LastIndex(fullMethod, "/")+1:]]
+	if !ok {
+		rules = l.fallback
+	}
+
+	results := make([]ratelimit.Result, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.policy.Enabled() {
+			continue
+		}
+
+		if key := rule.key(ctx, req); key != "" {
+			results = append(results, l.limiter.Take(rule.policy, key))
+		}
+	}
+
+	return ratelimit.Strictest(results)
+}
+
+// byIP keys a rule by the caller's address
+func byIP(ctx context.Context, req interface{}) string {
+	return requestInfo(ctx).IP
+}
+
+// byEmail keys a rule by the email in the request
+func byEmail(ctx context.Context, req interface{}) string {
+	if r, ok := req.(emailRequest); ok {
+		return strings.ToLower(r.GetEmail())
+	}
+	return ""
+}
+
+// byClient keys a rule by the subject of a valid bearer token in the call metadata.
+// Calls without one are not keyed, so a client cannot be charged for calls it did not make.
+func byClient(ctx context.Context, req interface{}) string {
+	claims, err := utils.ParseTokenClaims(bearerToken(ctx))
+	if err != nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+// rateLimitHeaders describes a result in the x-ratelimit-* response headers
+func rateLimitHeaders(result ratelimit.Result) metadata.MD {
+	md := metadata.Pairs(
+		"x-ratelimit-limit", strconv.Itoa(result.Limit),
+		"x-ratelimit-remaining", strconv.Itoa(result.Remaining),
+		"x-ratelimit-reset", strconv.Itoa(ceilSeconds(result.Reset)),
+	)
+	if !result.Allowed {
+		md.Set("retry-after", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+	return md
+}
+
+// rateLimitError is the status of a rejected call
+func rateLimitError(result ratelimit.Result) error {
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %d seconds", ceilSeconds(result.RetryAfter))
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -2,26 +2,46 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
+	"net"
+	"strings"
+	"sync"
 
-	pb "github.com/diplom/auth-service/proto"
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
 	"github.com/diplom/auth-service/internal/utils"
+	pb "github.com/diplom/auth-service/proto"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	// maxBatchSize is the largest number of tokens accepted by ValidateTokens
+	maxBatchSize = 1000
+	// batchWorkers is the number of tokens validated concurrently within one batch
+	batchWorkers = 8
+)
+
 // Server is the gRPC server implementation
 type Server struct {
 	pb.UnimplementedAuthServiceServer
+	authService *service.AuthService
 }
 
 // NewServer creates a new gRPC server instance
-func NewServer() *Server {
-	return &Server{}
+func NewServer(authService *service.AuthService) *Server {
+	return &Server{authService: authService}
 }
 
-// ValidateToken validates a JWT token
+// ValidateToken validates a JWT token.
+// With strict set, it also checks that the user still exists and has the role in the token.
 func (s *Server) ValidateToken(ctx context.Context, req *pb.TokenRequest) (*pb.TokenResponse, error) {
 	// Check if token is empty
 	if req.Token == "" {
@@ -29,26 +49,304 @@ func (s *Server) ValidateToken(ctx context.Context, req *pb.TokenRequest) (*pb.T
 		return nil, status.Error(codes.InvalidArgument, "token cannot be empty")
 	}
 
+	return s.validate(req.Token, req.Strict), nil
+}
+
+// ValidateTokens validates many tokens in one call and returns the results in request order
+func (s *Server) ValidateTokens(ctx context.Context, req *pb.ValidateTokensRequest) (*pb.ValidateTokensResponse, error) {
+	if len(req.Tokens) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d tokens can be validated per call", maxBatchSize)
+	}
+
+	results := make([]*pb.TokenResponse, len(req.Tokens))
+
+	// Validate in parallel with a bounded number of workers; each result keeps its index
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < batchWorkers && w < len(req.Tokens); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = s.validate(req.Tokens[i], req.Strict)
+			}
+		}()
+	}
+
+	for i := range req.Tokens {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	return &pb.ValidateTokensResponse{Results: results}, nil
+}
+
+// ValidateTokenStream validates tokens sent over a long-lived stream, answering each in order
+func (s *Server) ValidateTokenStream(stream pb.AuthService_ValidateTokenStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(s.validate(req.Token, req.Strict)); err != nil {
+			return err
+		}
+	}
+}
+
+// validate validates a single token and describes the result
+func (s *Server) validate(token string, strict bool) *pb.TokenResponse {
+	if token == "" {
+		return &pb.TokenResponse{Valid: false, Reason: utils.ReasonMalformed}
+	}
+
 	// Parse and validate the token
-	userID, role, err := utils.ParseToken(req.Token)
-	if err != nil {
-		log.Printf("Token validation failed: %v", err)
+	validation := s.authService.ValidateToken(token, strict)
+	if !validation.Valid {
 		return &pb.TokenResponse{
 			Valid:  false,
-			UserId: "",
-			Role:   "",
-		}, nil
+			Reason: validation.Reason,
+		}
+	}
+
+	return tokenResponse(validation.Claims)
+}
+
+// Register creates a new user and returns the first token pair,
+// or no tokens when the email address must be verified first
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AuthResponse, error) {
+	result, err := s.authService.Register(req.Email, req.Password, requestInfo(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
 	}
 
-	// Return successful response
-	return &pb.TokenResponse{
-		Valid:  true,
-		UserId: userID.String(),
-		Role:   role,
+	return authResponse(result), nil
+}
+
+// Login verifies the user's credentials and returns a token pair,
+// or an MFA challenge when the user has a second factor
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	result, err := s.authService.Login(req.Email, req.Password, requestInfo(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return authResponse(result), nil
+}
+
+// VerifyMFA completes a login with the second factor code
+func (s *Server) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (*pb.AuthResponse, error) {
+	result, err := s.authService.CompleteMFALogin(req.MfaToken, req.Code, requestInfo(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return authResponse(result), nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair
+func (s *Server) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	tokenPair, err := s.authService.Refresh(req.RefreshToken, requestInfo(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return &pb.RefreshTokenResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		ExpiresAt:    tokenPair.ExpiresAt,
 	}, nil
 }
 
+// Logout revokes an access token and its refresh token family
+func (s *Server) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	if err := s.authService.LogoutWithToken(req.AccessToken, req.RefreshToken, requestInfo(ctx)); err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return &pb.LogoutResponse{Success: true}, nil
+}
+
+// GetUser returns a user by ID. The caller authenticates with an access token of that user,
+// or a service token with the users:read scope, in the "authorization: Bearer" metadata.
+func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserResponse, error) {
+	validation := s.authService.ValidateToken(bearerToken(ctx), true)
+	if !validation.Valid {
+		return nil, status.Error(codes.Unauthenticated, "a valid bearer token is required")
+	}
+
+	userID, err := uuid.Parse(req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid user ID")
+	}
+
+	user, err := s.authService.LookupUser(validation.Claims, userID)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return &pb.UserResponse{User: userMessage(user)}, nil
+}
+
+// tokenResponse describes a valid token's claims
+func tokenResponse(claims *utils.TokenClaims) *pb.TokenResponse {
+	response := &pb.TokenResponse{
+		Valid:       true,
+		UserId:      claims.UserID,
+		Role:        claims.Role,
+		Email:       claims.Email,
+		SessionId:   claims.FamilyID,
+		Scopes:      claims.Scopes(),
+		SubjectType: claims.SubjectKind(),
+		ClientId:    claims.ClientID,
+		Audience:    claims.Audience,
+		Amr:         claims.AMR,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Unix()
+	}
+	return response
+}
+
+// authResponse converts a registration or login result to its protobuf form
+func authResponse(result *service.AuthResult) *pb.AuthResponse {
+	if result.MFA != nil {
+		return &pb.AuthResponse{
+			User:         userMessage(result.User),
+			MfaRequired:  true,
+			MfaToken:     result.MFA.Token,
+			MfaExpiresAt: result.MFA.ExpiresAt,
+			MfaMethods:   result.MFA.Methods,
+		}
+	}
+
+	return &pb.AuthResponse{
+		User:         userMessage(result.User),
+		AccessToken:  result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		ExpiresAt:    result.Tokens.ExpiresAt,
+	}
+}
+
+// userMessage converts a user to its protobuf form.
+// LastLoginAt stays zero for users who have never logged in.
+func userMessage(user *models.User) *pb.User {
+	message := &pb.User{
+		Id:            user.ID.String(),
+		Email:         user.Email,
+		Role:          user.Role,
+		CreatedAt:     user.CreatedAt.Unix(),
+		EmailVerified: user.EmailVerified,
+		Name:          user.Name,
+	}
+	if user.LastLoginAt != nil {
+		message.LastLoginAt = user.LastLoginAt.Unix()
+	}
+	return message
+}
+
+// requestInfo describes the peer of the call for the service layer
+func requestInfo(ctx context.Context) service.RequestInfo {
+	var info service.RequestInfo
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(info.IP); err == nil {
+			info.IP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			info.UserAgent = values[0]
+		}
+		if values := md.Get("x-device-name"); len(values) > 0 {
+			info.DeviceName = values[0]
+		}
+	}
+	return info
+}
+
+// bearerToken returns the token of the "authorization: Bearer" call metadata, or an empty string
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 || len(values[0]) < 7 || !strings.EqualFold(values[0][:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(values[0][7:])
+}
+
+// statusFromError maps service errors to gRPC status codes
+func statusFromError(err error) error {
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return passwordPolicyStatus(policyErr)
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrUserExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidToken),
+		errors.Is(err, service.ErrTokenRevoked),
+		errors.Is(err, service.ErrTokenReused),
+		errors.Is(err, service.ErrInvalidMFACode):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrInsufficientScope), errors.Is(err, service.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		log.Printf("Internal error: %v", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// passwordPolicyStatus returns InvalidArgument with a BadRequest detail listing every policy violation
+func passwordPolicyStatus(policyErr *service.PasswordPolicyError) error {
+	st := status.New(codes.InvalidArgument, policyErr.Error())
+
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range policyErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       "password",
+			Description: violation.Code + ": " + violation.Message,
+		})
+	}
+
+	detailed, err := st.WithDetails(badRequest)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
 // RegisterGRPCServer registers the gRPC server with a grpc.Server instance
-func RegisterGRPCServer(grpcServer *grpc.Server) {
-	pb.RegisterAuthServiceServer(grpcServer, NewServer())
+func RegisterGRPCServer(grpcServer *grpc.Server, authService *service.AuthService) {
+	pb.RegisterAuthServiceServer(grpcServer, NewServer(authService))
 }
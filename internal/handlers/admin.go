@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRole is the role required for administrative endpoints
+const AdminRole = "admin"
+
+// KeyHandler handles signing key administration
+type KeyHandler struct {
+	keys *utils.KeyManager
+}
+
+// NewKeyHandler creates a new KeyHandler instance
+func NewKeyHandler(keys *utils.KeyManager) *KeyHandler {
+	return &KeyHandler{keys: keys}
+}
+
+// ListKeysHandler lists the signing keys known to the service
+func (h *KeyHandler) ListKeysHandler(c *gin.Context) {
+	keys := h.keys.Keys()
+
+	infos := make([]models.SigningKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, signingKeyInfo(key))
+	}
+
+	c.JSON(http.StatusOK, infos)
+}
+
+// GenerateKeyHandler generates a new key that verifies tokens but does not sign them yet
+func (h *KeyHandler) GenerateKeyHandler(c *gin.Context) {
+	var req models.GenerateKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Printf("Invalid request: %v", err)
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+			return
+		}
+	}
+
+	key, err := h.keys.GenerateKey(req.Algorithm)
+	if err != nil {
+		log.Printf("Error generating signing key: %v", err)
+		c.JSON(keyErrorStatus(err), models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, signingKeyInfo(key))
+}
+
+// PromoteKeyHandler makes a key the active signing key and retires the previous one
+func (h *KeyHandler) PromoteKeyHandler(c *gin.Context) {
+	if err := h.keys.PromoteKey(c.Param("kid")); err != nil {
+		log.Printf("Error promoting signing key: %v", err)
+		c.JSON(keyErrorStatus(err), models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, signingKeyInfo(h.keys.ActiveKey()))
+}
+
+// RetireKeyHandler retires a key that is not active
+func (h *KeyHandler) RetireKeyHandler(c *gin.Context) {
+	if err := h.keys.RetireKey(c.Param("kid")); err != nil {
+		log.Printf("Error retiring signing key: %v", err)
+		c.JSON(keyErrorStatus(err), models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Key retired"})
+}
+
+// RotateKeyHandler generates a new key, promotes it and retires the previous active key
+func (h *KeyHandler) RotateKeyHandler(c *gin.Context) {
+	var req models.GenerateKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Printf("Invalid request: %v", err)
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+			return
+		}
+	}
+
+	key, err := h.keys.RotateKey(req.Algorithm)
+	if err != nil {
+		log.Printf("Error rotating signing key: %v", err)
+		c.JSON(keyErrorStatus(err), models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, signingKeyInfo(key))
+}
+
+// signingKeyInfo describes a key for the admin API
+func signingKeyInfo(key *utils.SigningKey) models.SigningKeyInfo {
+	return models.SigningKeyInfo{
+		ID:        key.ID,
+		Algorithm: key.Method.Alg(),
+		Status:    string(key.Status),
+		Static:    key.Static,
+		CreatedAt: key.CreatedAt,
+		RetiredAt: key.RetiredAt,
+		ExpiresAt: key.ExpiresAt(),
+	}
+}
+
+// keyErrorStatus maps key manager errors to HTTP status codes
+func keyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrUnknownKey):
+		return http.StatusNotFound
+	case errors.Is(err, utils.ErrUnsupportedAlgorithm), errors.Is(err, utils.ErrKeyOperationNotAllowed):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// SetupAdminRoutes sets up the administrative routes
+func SetupAdminRoutes(router *gin.Engine, keyHandler *KeyHandler, clientHandler *ClientHandler, userHandler *UserHandler,
+	auditHandler *AuditHandler, roleHandler *RoleHandler) {
+	admin := router.Group("/admin", AuthMiddleware(), RequireRole(AdminRole))
+	{
+		admin.GET("/keys", keyHandler.ListKeysHandler)
+		admin.POST("/keys", keyHandler.GenerateKeyHandler)
+		admin.POST("/keys/rotate", keyHandler.RotateKeyHandler)
+		admin.POST("/keys/:kid/promote", keyHandler.PromoteKeyHandler)
+		admin.POST("/keys/:kid/retire", keyHandler.RetireKeyHandler)
+
+		admin.GET("/clients", clientHandler.ListClientsHandler)
+		admin.POST("/clients", clientHandler.CreateClientHandler)
+		admin.DELETE("/clients/:id", clientHandler.DeleteClientHandler)
+
+		admin.POST("/users/:id/unlock", userHandler.UnlockUserHandler)
+		admin.GET("/users/:id/sessions", userHandler.ListUserSessionsHandler)
+		admin.DELETE("/users/:id/sessions/:sid", userHandler.RevokeUserSessionHandler)
+		admin.GET("/users/:id/login-history", userHandler.UserLoginHistoryHandler)
+		admin.GET("/users/:id/roles", roleHandler.GetUserRolesHandler)
+		admin.PUT("/users/:id/roles", roleHandler.SetUserRolesHandler)
+
+		admin.GET("/roles", roleHandler.ListRolesHandler)
+		admin.POST("/roles", roleHandler.CreateRoleHandler)
+		admin.PUT("/roles/:name", roleHandler.UpdateRoleHandler)
+		admin.DELETE("/roles/:name", roleHandler.DeleteRoleHandler)
+
+		admin.GET("/permissions", roleHandler.ListPermissionsHandler)
+		admin.POST("/permissions", roleHandler.CreatePermissionHandler)
+		admin.DELETE("/permissions/:name", roleHandler.DeletePermissionHandler)
+
+		admin.GET("/audit-events", auditHandler.ListAuditEventsHandler)
+	}
+}
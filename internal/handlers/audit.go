@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuditHandler serves the audit log to administrators
+type AuditHandler struct {
+	authService *service.AuthService
+}
+
+// NewAuditHandler creates a new AuditHandler instance
+func NewAuditHandler(authService *service.AuthService) *AuditHandler {
+	return &AuditHandler{authService: authService}
+}
+
+// ListAuditEventsHandler returns a filtered page of the audit log, newest first
+func (h *AuditHandler) ListAuditEventsHandler(c *gin.Context) {
+	filter, err := auditEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.authService.ListAuditEvents(filter)
+	if err != nil {
+		log.Printf("Error listing audit events: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to list audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// auditEventFilter reads the audit log filter from the query string
+func auditEventFilter(c *gin.Context) (models.AuditEventFilter, error) {
+	filter := models.AuditEventFilter{
+		Event:   c.Query("event"),
+		Outcome: c.Query("outcome"),
+		ActorID: c.Query("actor_id"),
+		IP:      c.Query("ip"),
+	}
+
+	if value := c.Query("subject_id"); value != "" {
+		subjectID, err := uuid.Parse(value)
+		if err != nil {
+			return filter, errors.New("invalid subject_id")
+		}
+		filter.SubjectID = &subjectID
+	}
+
+	var err error
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return filter, err
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return filter, errors.New("invalid limit")
+		}
+		filter.Limit = limit
+	}
+
+	if value := c.Query("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before < 1 {
+			return filter, errors.New("invalid before")
+		}
+		filter.Before = before
+	}
+
+	return filter, nil
+}
+
+// queryTime parses an optional RFC 3339 timestamp from the query string
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, expected an RFC 3339 timestamp", name)
+	}
+
+	// Timestamps are stored in UTC
+	t = t.UTC()
+	return &t, nil
+}
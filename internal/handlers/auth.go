@@ -1,25 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/diplom/auth-service/internal/models"
-	"github.com/diplom/auth-service/internal/repository"
-	"github.com/diplom/auth-service/internal/utils"
+	"github.com/diplom/auth-service/internal/ratelimit"
+	"github.com/diplom/auth-service/internal/service"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthHandler handles authentication related operations
 type AuthHandler struct {
-	userRepo *repository.UserRepository
+	authService *service.AuthService
 }
 
 // NewAuthHandler creates a new AuthHandler instance
-func NewAuthHandler(userRepo *repository.UserRepository) *AuthHandler {
-	return &AuthHandler{userRepo: userRepo}
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+	return &AuthHandler{authService: authService}
 }
 
 // RegisterHandler handles user registration
@@ -33,66 +33,112 @@ func (h *AuthHandler) RegisterHandler(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
-	exists, err := h.userRepo.UserExists(req.Email)
+	// Create the user and issue tokens
+	result, err := h.authService.Register(req.Email, req.Password, requestInfo(c))
 	if err != nil {
-		log.Printf("Error checking if user exists: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		if passwordPolicyError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidArgument):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		case errors.Is(err, service.ErrUserExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "User with this email already exists"})
+		default:
+			log.Printf("Error registering user: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create user"})
+		}
 		return
 	}
 
-	if exists {
-		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "User with this email already exists"})
-		return
-	}
+	// Return success response with enhanced data; tokens are left out until the email is verified if required
+	c.JSON(http.StatusCreated, models.UserRegisterResponse{
+		UserID:        result.User.ID.String(),
+		Email:         result.User.Email,
+		EmailVerified: result.User.EmailVerified,
+		Role:          result.User.Role,
+		AccessToken:   result.Tokens.AccessToken,
+		RefreshToken:  result.Tokens.RefreshToken,
+		ExpiresAt:     result.Tokens.ExpiresAt,
+		CreatedAt:     result.User.CreatedAt,
+	})
+}
 
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Printf("Error hashing password: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to process password"})
+// LoginHandler handles user login
+func (h *AuthHandler) LoginHandler(c *gin.Context) {
+	var req models.UserLoginRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
 		return
 	}
 
-	// Create the user
-	userID, err := h.userRepo.CreateUser(req.Email, string(hashedPassword))
+	// Verify credentials and issue tokens
+	result, err := h.authService.Login(req.Email, req.Password, requestInfo(c))
 	if err != nil {
-		log.Printf("Error creating user: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create user"})
+		if tooManyAttempts(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidArgument):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		case errors.Is(err, service.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid email or password"})
+		case errors.Is(err, service.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Email address is not verified"})
+		default:
+			log.Printf("Error logging in: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to generate authentication tokens"})
+		}
 		return
 	}
 
-	// Get full user details
-	user, err := h.userRepo.GetUserByID(userID)
-	if err != nil {
-		log.Printf("Error fetching created user: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "User created but failed to fetch details"})
+	// Users with a second factor continue at /auth/login/mfa
+	if result.MFA != nil {
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    result.MFA.Token,
+			ExpiresAt:   result.MFA.ExpiresAt,
+			Methods:     result.MFA.Methods,
+		})
 		return
 	}
 
-	// Generate tokens
-	tokenPair, err := utils.GenerateTokenPair(user.ID, user.Role)
-	if err != nil {
-		log.Printf("Error generating tokens: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to generate authentication tokens"})
-		return
+	c.JSON(http.StatusOK, loginResponse(result))
+}
+
+// tooManyAttempts answers 429 with Retry-After if err is a login lockout and reports whether it did
+func tooManyAttempts(c *gin.Context, err error) bool {
+	var lockout *service.LockoutError
+	if !errors.As(err, &lockout) {
+		return false
 	}
 
-	// Return success response with enhanced data
-	c.JSON(http.StatusCreated, models.UserRegisterResponse{
-		UserID:       user.ID.String(),
-		Email:        user.Email,
-		Role:         user.Role,
-		AccessToken:  tokenPair.AccessToken,
-		RefreshToken: tokenPair.RefreshToken,
-		ExpiresAt:    tokenPair.ExpiresAt,
-		CreatedAt:    user.CreatedAt,
-	})
+	c.Header("Retry-After", strconv.Itoa(lockout.RetrySeconds()))
+	c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: "Too many failed login attempts, try again later"})
+	return true
 }
 
-// LoginHandler handles user login
-func (h *AuthHandler) LoginHandler(c *gin.Context) {
-	var req models.UserLoginRequest
+// loginResponse builds the response of a completed login.
+// LastLoginAt is the login before this one, as the user was loaded before the login was recorded.
+func loginResponse(result *service.AuthResult) models.UserLoginResponse {
+	return models.UserLoginResponse{
+		UserID:       result.User.ID.String(),
+		Email:        result.User.Email,
+		Role:         result.User.Role,
+		AccessToken:  result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		ExpiresAt:    result.Tokens.ExpiresAt,
+		LastLoginAt:  result.User.LastLoginAt,
+	}
+}
+
+// RefreshHandler exchanges a refresh token for a new token pair.
+// Every refresh token can be used only once; replaying a rotated token revokes its whole family.
+func (h *AuthHandler) RefreshHandler(c *gin.Context) {
+	var req models.TokenRefreshRequest
 
 	// Parse request body
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -101,51 +147,108 @@ func (h *AuthHandler) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	// Get user by email
-	user, err := h.userRepo.GetUserByEmail(req.Email)
+	tokenPair, err := h.authService.Refresh(req.RefreshToken, requestInfo(c))
 	if err != nil {
-		log.Printf("User not found: %v", err)
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid email or password"})
+		switch {
+		case errors.Is(err, service.ErrInvalidArgument):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		case errors.Is(err, service.ErrInvalidToken):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid refresh token"})
+		case errors.Is(err, service.ErrTokenRevoked):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Refresh token has been revoked"})
+		case errors.Is(err, service.ErrTokenReused):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Refresh token has already been used"})
+		default:
+			log.Printf("Error refreshing tokens: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		}
 		return
 	}
 
-	// Compare passwords
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
-	if err != nil {
-		log.Printf("Invalid password: %v", err)
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid email or password"})
+	c.JSON(http.StatusOK, models.TokenRefreshResponse{
+		AccessToken:  tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		ExpiresAt:    tokenPair.ExpiresAt,
+	})
+}
+
+// LogoutHandler revokes the current access token and its refresh token family
+func (h *AuthHandler) LogoutHandler(c *gin.Context) {
+	claims := currentClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
 		return
 	}
 
-	// Generate tokens
-	tokenPair, err := utils.GenerateTokenPair(user.ID, user.Role)
-	if err != nil {
-		log.Printf("Error generating tokens: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to generate authentication tokens"})
-		return
+	// The body is optional and may name an additional refresh token to revoke
+	var req models.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Printf("Invalid request: %v", err)
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+			return
+		}
 	}
 
-	// Record current time as last login
-	lastLoginAt := time.Now()
+	if err := h.authService.Logout(claims, req.RefreshToken, requestInfo(c)); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+		log.Printf("Error logging out: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to log out"})
+		return
+	}
 
-	// Return success response with enhanced data
-	c.JSON(http.StatusOK, models.UserLoginResponse{
-		UserID:       user.ID.String(),
-		Email:        user.Email,
-		Role:         user.Role,
-		AccessToken:  tokenPair.AccessToken,
-		RefreshToken: tokenPair.RefreshToken,
-		ExpiresAt:    tokenPair.ExpiresAt,
-		LastLoginAt:  lastLoginAt,
-	})
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Logged out successfully"})
 }
 
 // SetupRoutes sets up the authentication routes
-func SetupRoutes(router *gin.Engine, authHandler *AuthHandler) {
+func SetupRoutes(router *gin.Engine, authHandler *AuthHandler, limiter *ratelimit.Limiter) {
+	loginLimit := RateLimit(limiter, RateLimitRule{Policy: ratelimit.LoginIPPolicy, Key: byIP}, RateLimitRule{Policy: ratelimit.LoginEmailPolicy, Key: byEmail})
+	// Other steps that check a credential or a one-time code share the address half of the login limit
+	credentialLimit := RateLimit(limiter, RateLimitRule{Policy: ratelimit.LoginIPPolicy, Key: byIP})
+	mailLimit := RateLimit(limiter, RateLimitRule{Policy: mailIPPolicy, Key: byIP}, RateLimitRule{Policy: mailEmailPolicy, Key: byEmail})
+
 	// Group auth routes
 	auth := router.Group("/auth")
 	{
-		auth.POST("/register", authHandler.RegisterHandler)
-		auth.POST("/login", authHandler.LoginHandler)
+		auth.POST("/register", RateLimit(limiter, RateLimitRule{Policy: ratelimit.RegisterPolicy, Key: byIP}), authHandler.RegisterHandler)
+		auth.POST("/login", loginLimit, authHandler.LoginHandler)
+		auth.POST("/refresh", RateLimit(limiter, RateLimitRule{Policy: ratelimit.RefreshPolicy, Key: byIP}), authHandler.RefreshHandler)
+		auth.POST("/logout", AuthMiddleware(), authHandler.LogoutHandler)
+		auth.POST("/verify-email", credentialLimit, authHandler.VerifyEmailHandler)
+		auth.POST("/verify-email/resend", mailLimit, authHandler.ResendVerificationHandler)
+		auth.POST("/password/forgot", mailLimit, authHandler.ForgotPasswordHandler)
+		auth.POST("/password/reset", credentialLimit, authHandler.ResetPasswordHandler)
+		auth.POST("/password/change", credentialLimit, AuthMiddleware(), authHandler.ChangePasswordHandler)
+		auth.GET("/me", AuthMiddleware(), authHandler.MeHandler)
+		auth.PATCH("/me", AuthMiddleware(), authHandler.UpdateMeHandler)
+		auth.GET("/sessions", AuthMiddleware(), authHandler.ListSessionsHandler)
+		auth.DELETE("/sessions", AuthMiddleware(), authHandler.RevokeOtherSessionsHandler)
+		auth.DELETE("/sessions/:id", AuthMiddleware(), authHandler.RevokeSessionHandler)
+		auth.GET("/login-history", AuthMiddleware(), authHandler.LoginHistoryHandler)
+		auth.POST("/login/mfa", credentialLimit, authHandler.MFALoginHandler)
+
+		mfa := auth.Group("/mfa", AuthMiddleware())
+		mfa.POST("/totp/enroll", authHandler.EnrollTOTPHandler)
+		mfa.POST("/totp/confirm", authHandler.ConfirmTOTPHandler)
+		mfa.POST("/totp/disable", authHandler.DisableTOTPHandler)
+		mfa.GET("/recovery-codes", authHandler.RecoveryCodesStatusHandler)
+		mfa.POST("/recovery-codes", authHandler.RegenerateRecoveryCodesHandler)
+
+		auth.POST("/login/mfa/webauthn/begin", credentialLimit, authHandler.BeginWebAuthnMFAHandler)
+		auth.POST("/login/mfa/webauthn/finish", credentialLimit, authHandler.FinishWebAuthnMFAHandler)
+
+		webauthn := auth.Group("/webauthn")
+		webauthn.POST("/login/begin", credentialLimit, authHandler.BeginWebAuthnLoginHandler)
+		webauthn.POST("/login/finish", credentialLimit, authHandler.FinishWebAuthnLoginHandler)
+		webauthn.POST("/register/begin", AuthMiddleware(), authHandler.BeginWebAuthnRegistrationHandler)
+		webauthn.POST("/register/finish", AuthMiddleware(), authHandler.FinishWebAuthnRegistrationHandler)
+		webauthn.GET("/credentials", AuthMiddleware(), authHandler.ListWebAuthnCredentialsHandler)
+		webauthn.DELETE("/credentials/:id", AuthMiddleware(), authHandler.DeleteWebAuthnCredentialHandler)
 	}
+
+	// Public keys for offline token verification
+	router.GET("/.well-known/jwks.json", JWKSHandler)
 }
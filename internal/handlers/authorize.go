@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates/authorize.html templates/webauthn.js
+var templateFS embed.FS
+
+// authorizePage is the hosted login and consent page of the authorization endpoint
+var authorizePage = template.Must(template.ParseFS(templateFS, "templates/authorize.html"))
+
+const (
+	// authorizeCSRFCookie holds the token that the authorization form must post back
+	authorizeCSRFCookie = "authorize_csrf"
+	// authorizeCSRFField is the form field carrying the token
+	authorizeCSRFField = "csrf_token"
+	// csrfTokenBytes is the entropy of a CSRF token
+	csrfTokenBytes = 32
+)
+
+// authorizePageData is rendered into the authorization page
+type authorizePageData struct {
+	ClientName string
+	Scopes     []string
+	Request    *service.AuthorizationRequest
+	Email      string
+	MFAToken   string
+	MFAMethods []string
+	CSRFToken  string
+	Error      string
+	Fatal      bool
+}
+
+// HasMFAMethod reports whether the second login step can use the given method
+func (d authorizePageData) HasMFAMethod(method string) bool {
+	for _, m := range d.MFAMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// MFAMethodList returns the second factors as a space-separated list for the form
+func (d authorizePageData) MFAMethodList() string {
+	return strings.Join(d.MFAMethods, " ")
+}
+
+// AuthorizeHandler shows the login and consent page for an authorization request (RFC 6749 section 4.1.1)
+func (h *OAuthHandler) AuthorizeHandler(c *gin.Context) {
+	req := authorizationRequest(c.Query)
+
+	client, ok := h.checkAuthorizationRequest(c, req)
+	if !ok {
+		return
+	}
+
+	renderAuthorizePage(c, http.StatusOK, authorizePageData{
+		ClientName: client.Name,
+		Scopes:     strings.Fields(req.Scope),
+		Request:    req,
+	})
+}
+
+// AuthorizeSubmitHandler handles the login and consent form and redirects back to the client with a code
+func (h *OAuthHandler) AuthorizeSubmitHandler(c *gin.Context) {
+	// A form posted from another site cannot carry the token of our cookie
+	if !validAuthorizeCSRF(c) {
+		renderAuthorizePage(c, http.StatusForbidden, authorizePageData{Error: "The sign-in form has expired, please start again from the application", Fatal: true})
+		return
+	}
+
+	req := authorizationRequest(c.PostForm)
+
+	client, ok := h.checkAuthorizationRequest(c, req)
+	if !ok {
+		return
+	}
+
+	if c.PostForm("action") != "allow" {
+		redirectWithParams(c, req.RedirectURI, map[string]string{"error": "access_denied", "state": req.State})
+		return
+	}
+
+	page := authorizePageData{
+		ClientName: client.Name,
+		Scopes:     strings.Fields(req.Scope),
+		Request:    req,
+	}
+
+	// A passkey or security key assertion replaces the password or the verification code
+	if credential := c.PostForm("webauthn_credential"); credential != "" {
+		h.authorizeWithWebAuthn(c, client, req, page, credential)
+		return
+	}
+
+	// The second step of a login with MFA carries the challenge token instead of a password
+	if mfaToken := c.PostForm("mfa_token"); mfaToken != "" {
+		code, err := h.authService.AuthorizeWithMFA(client, req, mfaToken, c.PostForm("code"), requestInfo(c))
+		if err != nil {
+			var lockout *service.LockoutError
+			switch {
+			case errors.As(err, &lockout):
+				c.Header("Retry-After", strconv.Itoa(lockout.RetrySeconds()))
+				page.Error = fmt.Sprintf("Too many failed sign-in attempts, try again in %s", retryAfterText(lockout))
+				renderAuthorizePage(c, http.StatusTooManyRequests, page)
+			case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrInvalidArgument):
+				page.MFAToken = mfaToken
+				page.MFAMethods = strings.Fields(c.PostForm("mfa_methods"))
+				page.Error = "Invalid verification code"
+				renderAuthorizePage(c, http.StatusUnauthorized, page)
+			case errors.Is(err, service.ErrInvalidToken):
+				page.Error = "The sign-in attempt has expired, please sign in again"
+				renderAuthorizePage(c, http.StatusUnauthorized, page)
+			default:
+				log.Printf("Error issuing authorization code for client %s: %v", client.ID, err)
+				redirectWithParams(c, req.RedirectURI, map[string]string{"error": "server_error", "state": req.State})
+			}
+			return
+		}
+
+		redirectWithParams(c, req.RedirectURI, map[string]string{"code": code, "state": req.State})
+		return
+	}
+
+	page.Email = c.PostForm("email")
+	result, err := h.authService.Authorize(client, req, page.Email, c.PostForm("password"), requestInfo(c))
+	if err != nil {
+		var lockout *service.LockoutError
+		if errors.As(err, &lockout) {
+			c.Header("Retry-After", strconv.Itoa(lockout.RetrySeconds()))
+			page.Error = fmt.Sprintf("Too many failed sign-in attempts, try again in %s", retryAfterText(lockout))
+			renderAuthorizePage(c, http.StatusTooManyRequests, page)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrInvalidArgument) {
+			page.Error = "Invalid email or password"
+			renderAuthorizePage(c, http.StatusUnauthorized, page)
+			return
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			page.Error = "Please confirm your email address using the link we sent you"
+			renderAuthorizePage(c, http.StatusForbidden, page)
+			return
+		}
+		log.Printf("Error issuing authorization code for client %s: %v", client.ID, err)
+		redirectWithParams(c, req.RedirectURI, map[string]string{"error": "server_error", "state": req.State})
+		return
+	}
+
+	if result.MFA != nil {
+		page.MFAToken = result.MFA.Token
+		page.MFAMethods = result.MFA.Methods
+		renderAuthorizePage(c, http.StatusOK, page)
+		return
+	}
+
+	redirectWithParams(c, req.RedirectURI, map[string]string{"code": result.Code, "state": req.State})
+}
+
+// authorizeWithWebAuthn completes the login form with a WebAuthn assertion posted by the page script
+func (h *OAuthHandler) authorizeWithWebAuthn(c *gin.Context, client *models.OAuthClient, req *service.AuthorizationRequest, page authorizePageData, credentialJSON string) {
+	mfaToken := c.PostForm("mfa_token")
+
+	var credential models.PublicKeyCredential
+	err := json.Unmarshal([]byte(credentialJSON), &credential)
+	if err == nil {
+		var code string
+		code, err = h.authService.AuthorizeWithWebAuthn(client, req, mfaToken, c.PostForm("webauthn_session"), &credential)
+		if err == nil {
+			redirectWithParams(c, req.RedirectURI, map[string]string{"code": code, "state": req.State})
+			return
+		}
+	} else {
+		err = service.ErrInvalidArgument
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidWebAuthnResponse), errors.Is(err, service.ErrInvalidArgument):
+		page.MFAToken = mfaToken
+		if mfaToken != "" {
+			page.MFAMethods = strings.Fields(c.PostForm("mfa_methods"))
+		}
+		page.Error = "Security key verification failed"
+		renderAuthorizePage(c, http.StatusUnauthorized, page)
+	case errors.Is(err, service.ErrInvalidToken):
+		page.Error = "The sign-in attempt has expired, please sign in again"
+		renderAuthorizePage(c, http.StatusUnauthorized, page)
+	case errors.Is(err, service.ErrEmailNotVerified):
+		page.Error = "Please confirm your email address using the link we sent you"
+		renderAuthorizePage(c, http.StatusForbidden, page)
+	default:
+		log.Printf("Error issuing authorization code for client %s: %v", client.ID, err)
+		redirectWithParams(c, req.RedirectURI, map[string]string{"error": "server_error", "state": req.State})
+	}
+}
+
+// AuthorizeScriptHandler serves the script that adds passkey sign-in to the authorization page
+func AuthorizeScriptHandler(c *gin.Context) {
+	script, err := templateFS.ReadFile("templates/webauthn.js")
+	if err != nil {
+		log.Printf("Error reading authorization page script: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", script)
+}
+
+// checkAuthorizationRequest validates the client and redirect URI, then the remaining parameters.
+// Errors before the redirect URI is trusted are shown on the page; later ones are sent to the client.
+func (h *OAuthHandler) checkAuthorizationRequest(c *gin.Context, req *service.AuthorizationRequest) (*models.OAuthClient, bool) {
+	client, err := h.clientService.GetClient(req.ClientID)
+	if err != nil {
+		if !errors.Is(err, service.ErrClientNotFound) {
+			log.Printf("Error loading client %s: %v", req.ClientID, err)
+		}
+		renderAuthorizeError(c, "Unknown client")
+		return nil, false
+	}
+
+	redirectURI, err := h.clientService.ResolveRedirectURI(client, req.RedirectURI)
+	if err != nil {
+		renderAuthorizeError(c, "The redirect URI is not registered for this client")
+		return nil, false
+	}
+	req.RedirectURI = redirectURI
+
+	if err := h.authService.ValidateAuthorizationRequest(req); err != nil {
+		code := "invalid_request"
+		switch {
+		case errors.Is(err, service.ErrUnsupportedResponseType):
+			code = "unsupported_response_type"
+		case errors.Is(err, service.ErrInvalidScope):
+			code = "invalid_scope"
+		}
+		redirectWithParams(c, req.RedirectURI, map[string]string{
+			"error":             code,
+			"error_description": err.Error(),
+			"state":             req.State,
+		})
+		return nil, false
+	}
+
+	return client, true
+}
+
+// authorizationRequest reads the authorization parameters from the query or the form
+func authorizationRequest(get func(string) string) *service.AuthorizationRequest {
+	return &service.AuthorizationRequest{
+		ResponseType:        get("response_type"),
+		ClientID:            get("client_id"),
+		RedirectURI:         get("redirect_uri"),
+		Scope:               get("scope"),
+		State:               get("state"),
+		Nonce:               get("nonce"),
+		CodeChallenge:       get("code_challenge"),
+		CodeChallengeMethod: get("code_challenge_method"),
+	}
+}
+
+// renderAuthorizePage writes the authorization page with headers that keep it out of frames and caches.
+// Every form gets a fresh CSRF token, set both in a cookie and in a hidden field.
+func renderAuthorizePage(c *gin.Context, status int, data authorizePageData) {
+	if !data.Fatal {
+		token, err := utils.RandomToken(csrfTokenBytes)
+		if err != nil {
+			log.Printf("Error generating CSRF token: %v", err)
+			status, data = http.StatusInternalServerError, authorizePageData{Error: "Internal server error", Fatal: true}
+		} else {
+			data.CSRFToken = token
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     authorizeCSRFCookie,
+				Value:    token,
+				Path:     "/oauth/authorize",
+				HttpOnly: true,
+				Secure:   c.Request.TLS != nil || strings.HasPrefix(utils.Issuer(), "https://"),
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; script-src 'self'; connect-src 'self'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+
+	if err := authorizePage.Execute(c.Writer, data); err != nil {
+		log.Printf("Error rendering authorization page: %v", err)
+	}
+}
+
+// validAuthorizeCSRF reports whether the form posted the token of the cookie set with the page
+func validAuthorizeCSRF(c *gin.Context) bool {
+	cookie, err := c.Cookie(authorizeCSRFCookie)
+	if err != nil || cookie == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(c.PostForm(authorizeCSRFField))) == 1
+}
+
+// retryAfterText formats the wait of a lockout for people
+func retryAfterText(lockout *service.LockoutError) string {
+	seconds := lockout.RetrySeconds()
+	if seconds < 60 {
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+	return fmt.Sprintf("%d minutes", (seconds+59)/60)
+}
+
+// renderAuthorizeError shows an error that must not be sent to an untrusted redirect URI
+func renderAuthorizeError(c *gin.Context, message string) {
+	renderAuthorizePage(c, http.StatusBadRequest, authorizePageData{Error: message, Fatal: true})
+}
+
+// redirectWithParams redirects to the client's redirect URI with the given non-empty query parameters
+func redirectWithParams(c *gin.Context, redirectURI string, params map[string]string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		renderAuthorizeError(c, "Invalid redirect URI")
+		return
+	}
+
+	query := target.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	target.RawQuery = query.Encode()
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target.String())
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestRenderAuthorizePageSetsCSRFToken(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/oauth/authorize", nil)
+
+	renderAuthorizePage(c, http.StatusOK, authorizePageData{ClientName: "app", Request: &service.AuthorizationRequest{}})
+
+	var cookie *http.Cookie
+	for _, candidate := range w.Result().Cookies() {
+		if candidate.Name == authorizeCSRFCookie {
+			cookie = candidate
+		}
+	}
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("no CSRF cookie set")
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie HttpOnly = %v, SameSite = %v; want HttpOnly and strict", cookie.HttpOnly, cookie.SameSite)
+	}
+	if field := `name="csrf_token" value="` + cookie.Value + `"`; !strings.Contains(w.Body.String(), field) {
+		t.Errorf("page does not contain %s", field)
+	}
+}
+
+func TestAuthorizeSubmitRejectsMissingCSRFToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		field  string
+	}{
+		{name: "no cookie", field: "token"},
+		{name: "no field", cookie: "token"},
+		{name: "mismatch", cookie: "token", field: "other"},
+		{name: "both empty"},
+	}
+
+	// The handler has no services, so a request that got past the check would panic
+	h := &OAuthHandler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"action": {"allow"}, "client_id": {"app"}}
+			if tt.field != "" {
+				form.Set(authorizeCSRFField, tt.field)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: authorizeCSRFCookie, Value: tt.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = req
+			h.AuthorizeSubmitHandler(c)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientHandler handles OAuth client administration
+type ClientHandler struct {
+	clientService *service.ClientService
+}
+
+// NewClientHandler creates a new ClientHandler instance
+func NewClientHandler(clientService *service.ClientService) *ClientHandler {
+	return &ClientHandler{clientService: clientService}
+}
+
+// CreateClientHandler registers a new OAuth client and returns its secret once
+func (h *ClientHandler) CreateClientHandler(c *gin.Context) {
+	var req models.CreateClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	client, secret, err := h.clientService.CreateClient(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidArgument) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		log.Printf("Error creating client: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create client"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateClientResponse{
+		ClientID:      client.ID,
+		ClientSecret:  secret,
+		Name:          client.Name,
+		RedirectURIs:  client.RedirectURIs,
+		Public:        client.Public,
+		AllowedScopes: client.AllowedScopes,
+		Audience:      client.Audience,
+		CreatedAt:     client.CreatedAt,
+	})
+}
+
+// ListClientsHandler lists the registered OAuth clients
+func (h *ClientHandler) ListClientsHandler(c *gin.Context) {
+	clients, err := h.clientService.ListClients()
+	if err != nil {
+		log.Printf("Error listing clients: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to list clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteClientHandler removes an OAuth client
+func (h *ClientHandler) DeleteClientHandler(c *gin.Context) {
+	if err := h.clientService.DeleteClient(c.Param("id")); err != nil {
+		if errors.Is(err, service.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Client not found"})
+			return
+		}
+		log.Printf("Error deleting client: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete client"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Client deleted"})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmailHandler confirms an email address with the token from a verification link
+func (h *AuthHandler) VerifyEmailHandler(c *gin.Context) {
+	var req models.VerifyEmailRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	if _, err := h.authService.VerifyEmail(req.Token); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidArgument):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid or expired verification link"})
+		default:
+			log.Printf("Error verifying email: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Email address verified"})
+}
+
+// ResendVerificationHandler sends a new verification link.
+// The response is the same whether or not the address belongs to an unverified account.
+func (h *AuthHandler) ResendVerificationHandler(c *gin.Context) {
+	var req models.ResendVerificationRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	if err := h.authService.ResendVerificationEmail(req.Email); err != nil {
+		if errors.Is(err, service.ErrInvalidArgument) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+			return
+		}
+		log.Printf("Error resending verification email: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.MessageResponse{Message: "If the address belongs to an unverified account, a verification email has been sent"})
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys used to verify access tokens
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
+}
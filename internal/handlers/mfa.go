@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// MFALoginHandler completes a login that requires a second factor
+func (h *AuthHandler) MFALoginHandler(c *gin.Context) {
+	var req models.MFALoginRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	result, err := h.authService.CompleteMFALogin(req.MFAToken, req.Code, requestInfo(c))
+	if err != nil {
+		if tooManyAttempts(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidArgument):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		case errors.Is(err, service.ErrInvalidToken):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid or expired MFA token"})
+		case errors.Is(err, service.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid verification code"})
+		default:
+			log.Printf("Error completing MFA login: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to generate authentication tokens"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(result))
+}
+
+// EnrollTOTPHandler starts TOTP enrollment and returns the secret for the authenticator app
+func (h *AuthHandler) EnrollTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTOTP(userID)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPHandler enables TOTP once the first code from the authenticator app is verified.
+// The response carries recovery codes if this is the user's first second factor.
+func (h *AuthHandler) ConfirmTOTPHandler(c *gin.Context) {
+	userID, req, ok := mfaCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.authService.ConfirmTOTP(userID, req.Code)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, models.MFAEnabledResponse{
+		Message:       "Two-factor authentication enabled",
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// DisableTOTPHandler disables TOTP after checking a current code
+func (h *AuthHandler) DisableTOTPHandler(c *gin.Context) {
+	userID, req, ok := mfaCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DisableTOTP(userID, req.Code); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Two-factor authentication disabled"})
+}
+
+// RecoveryCodesStatusHandler reports how many recovery codes the current user has left
+func (h *AuthHandler) RecoveryCodesStatusHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	remaining, err := h.authService.RecoveryCodesRemaining(userID)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesStatusResponse{Remaining: remaining})
+}
+
+// RegenerateRecoveryCodesHandler replaces the current user's recovery codes with a new set
+func (h *AuthHandler) RegenerateRecoveryCodesHandler(c *gin.Context) {
+	claims := currentClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	recoveryCodes, err := h.authService.RegenerateRecoveryCodes(claims)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// mfaCodeRequest reads the current user and the code from the request body.
+// It writes the error response and returns false if either is missing.
+func mfaCodeRequest(c *gin.Context) (uuid.UUID, models.MFACodeRequest, bool) {
+	var req models.MFACodeRequest
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return uuid.Nil, req, false
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return uuid.Nil, req, false
+	}
+
+	return userID, req, true
+}
+
+// mfaError maps MFA service errors to HTTP responses
+func mfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Two-factor authentication is already enabled"})
+	case errors.Is(err, service.ErrMFANotEnrolled):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Two-factor authentication is not enrolled"})
+	case errors.Is(err, service.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid verification code"})
+	case errors.Is(err, service.ErrMFARequired):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Sign in with a second factor to manage recovery codes"})
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+	default:
+		log.Printf("Error managing two-factor authentication: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update two-factor authentication"})
+	}
+}
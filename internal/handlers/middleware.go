@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// claimsContextKey is the gin context key holding the authenticated token claims
+const claimsContextKey = "tokenClaims"
+
+// clientContextKey is the gin context key holding the authenticated OAuth client
+const clientContextKey = "oauthClient"
+
+// AuthMiddleware requires a valid bearer access token from a first-party login and stores its claims in the context.
+// Tokens issued to OAuth clients, on behalf of a user or for the client itself, are rejected:
+// a client granted a few scopes must not manage the user's account.
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
+			return
+		}
+
+		if claims.ClientID != "" || claims.IsService() {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Token issued to an OAuth client cannot be used here"})
+			return
+		}
+
+		c.Set(claimsContextKey, claims)
+		c.Next()
+	}
+}
+
+// UserInfoMiddleware requires a valid bearer access token of a user with the openid scope.
+// Unlike AuthMiddleware it accepts tokens issued to OAuth clients.
+func UserInfoMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
+			return
+		}
+
+		if claims.IsService() {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Token does not belong to a user"})
+			return
+		}
+		if !utils.HasScope(claims.Scope, utils.ScopeOpenID) {
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Token does not have the openid scope"})
+			return
+		}
+
+		c.Set(claimsContextKey, claims)
+		c.Next()
+	}
+}
+
+// authenticate parses the bearer access token and aborts the request when it is missing or invalid
+func authenticate(c *gin.Context) (*utils.TokenClaims, bool) {
+	tokenString := bearerToken(c)
+	if tokenString == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Missing bearer token"})
+		return nil, false
+	}
+
+	claims, err := utils.ParseTokenClaims(tokenString)
+	if err != nil {
+		log.Printf("Access token rejected: %v", err)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid or expired token"})
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// deviceNameHeader lets clients name the device shown in the session list
+const deviceNameHeader = "X-Device-Name"
+
+// requestInfo describes the client of the request for the service layer
+func requestInfo(c *gin.Context) service.RequestInfo {
+	info := service.RequestInfo{
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		DeviceName: c.GetHeader(deviceNameHeader),
+	}
+
+	// Authenticated callers are recorded as the actor in the audit log
+	if claims := currentClaims(c); claims != nil {
+		info.ActorID = claims.UserID
+		if claims.IsService() {
+			info.ActorID = claims.ClientID
+		}
+	}
+	return info
+}
+
+// currentClaims returns the claims stored by AuthMiddleware or UserInfoMiddleware
+func currentClaims(c *gin.Context) *utils.TokenClaims {
+	value, ok := c.Get(claimsContextKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*utils.TokenClaims)
+	return claims
+}
+
+// currentUserID returns the user ID of the authenticated user token
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	claims := currentClaims(c)
+	if claims == nil || claims.IsService() {
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// RequireRole allows the request only if the authenticated user has the given role.
+// It must run after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := currentClaims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		if !claims.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Insufficient permissions"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/ratelimit"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler handles the standard OAuth 2.0 endpoints
+type OAuthHandler struct {
+	authService   *service.AuthService
+	clientService *service.ClientService
+}
+
+// NewOAuthHandler creates a new OAuthHandler instance
+func NewOAuthHandler(authService *service.AuthService, clientService *service.ClientService) *OAuthHandler {
+	return &OAuthHandler{
+		authService:   authService,
+		clientService: clientService,
+	}
+}
+
+// TokenHandler handles the token endpoint (RFC 6749) for the authorization_code, password,
+// refresh_token and client_credentials grants
+func (h *OAuthHandler) TokenHandler(c *gin.Context) {
+	client := currentClient(c)
+
+	var result *service.OAuthTokenResult
+	var err error
+	switch c.PostForm("grant_type") {
+	case service.GrantTypeAuthorizationCode:
+		result, err = h.authService.AuthorizationCodeGrant(client, c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"), requestInfo(c))
+	case service.GrantTypePassword:
+		result, err = h.authService.PasswordGrant(client, c.PostForm("username"), c.PostForm("password"), c.PostForm("scope"), c.PostForm("nonce"), requestInfo(c))
+	case service.GrantTypeRefreshToken:
+		result, err = h.authService.RefreshTokenGrant(client, c.PostForm("refresh_token"), c.PostForm("scope"), requestInfo(c))
+	case service.GrantTypeClientCredentials:
+		result, err = h.authService.ClientCredentialsGrant(client, c.PostForm("scope"), requestedAudience(c))
+	case "":
+		oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	if err != nil {
+		var lockout *service.LockoutError
+		switch {
+		case errors.As(err, &lockout):
+			c.Header("Retry-After", strconv.Itoa(lockout.RetrySeconds()))
+			oauthError(c, http.StatusTooManyRequests, "invalid_grant", err.Error())
+		case errors.Is(err, service.ErrInvalidGrant):
+			oauthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		case errors.Is(err, service.ErrUnauthorizedClient):
+			oauthError(c, http.StatusBadRequest, "unauthorized_client", err.Error())
+		case errors.Is(err, service.ErrInvalidScope):
+			oauthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		case errors.Is(err, service.ErrInvalidTarget):
+			oauthError(c, http.StatusBadRequest, "invalid_target", err.Error())
+		case errors.Is(err, service.ErrInvalidArgument):
+			oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		default:
+			log.Printf("Error issuing tokens for client %s: %v", client.ID, err)
+			oauthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, models.OAuthTokenResponse{
+		AccessToken:  result.Tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    result.Tokens.ExpiresAt - time.Now().Unix(),
+		RefreshToken: result.Tokens.RefreshToken,
+		IDToken:      result.IDToken,
+		Scope:        result.Scope,
+	})
+}
+
+// IntrospectHandler handles token introspection requests (RFC 7662)
+func (h *OAuthHandler) IntrospectHandler(c *gin.Context) {
+	client := currentClient(c)
+
+	// Only confidential clients may learn about arbitrary tokens
+	if client.Public {
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Public clients cannot introspect tokens")
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	response, err := h.authService.IntrospectToken(token, c.PostForm("token_type_hint"))
+	if err != nil {
+		log.Printf("Error introspecting token for client %s: %v", client.ID, err)
+		oauthError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeHandler handles token revocation requests (RFC 7009)
+func (h *OAuthHandler) RevokeHandler(c *gin.Context) {
+	client := currentClient(c)
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	info := requestInfo(c)
+	info.ActorID = client.ID
+
+	// Invalid and unknown tokens are not an error for the caller
+	if err := h.authService.RevokeToken(token, c.PostForm("token_type_hint"), client, info); err != nil {
+		log.Printf("Error revoking token for client %s: %v", client.ID, err)
+		oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// ClientAuthMiddleware checks client credentials sent with HTTP Basic or in the form body
+// and stores the authenticated client in the context
+func (h *OAuthHandler) ClientAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, secret, ok := c.Request.BasicAuth()
+		if !ok {
+			clientID = c.PostForm("client_id")
+			secret = c.PostForm("client_secret")
+		}
+
+		client, err := h.clientService.AuthenticateClient(clientID, secret)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidClient) {
+				c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+				oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+			} else {
+				log.Printf("Error authenticating client: %v", err)
+				oauthError(c, http.StatusInternalServerError, "server_error", "")
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(clientContextKey, client)
+		c.Next()
+	}
+}
+
+// currentClient returns the client authenticated by ClientAuthMiddleware, or nil
+func currentClient(c *gin.Context) *models.OAuthClient {
+	value, ok := c.Get(clientContextKey)
+	if !ok {
+		return nil
+	}
+	client, _ := value.(*models.OAuthClient)
+	return client
+}
+
+// requestedAudience reads the audience parameter, accepting the RFC 8707 resource parameter as well
+func requestedAudience(c *gin.Context) string {
+	if audience := c.PostForm("audience"); audience != "" {
+		return audience
+	}
+	return c.PostForm("resource")
+}
+
+// oauthError writes an RFC 6749 error response
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, models.OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// SetupOAuthRoutes sets up the OAuth 2.0 and OpenID Connect routes
+func SetupOAuthRoutes(router *gin.Engine, oauthHandler *OAuthHandler, limiter *ratelimit.Limiter) {
+	// Clients are limited by their authenticated ID, so the limit runs after client authentication
+	clientAuth := oauthHandler.ClientAuthMiddleware()
+	clientLimit := RateLimit(limiter, RateLimitRule{Policy: ratelimit.ClientPolicy, Key: byClient})
+
+	oauth := router.Group("/oauth")
+	{
+		oauth.GET("/authorize", oauthHandler.AuthorizeHandler)
+		oauth.POST("/authorize", RateLimit(limiter, RateLimitRule{Policy: ratelimit.LoginIPPolicy, Key: byIP}, RateLimitRule{Policy: ratelimit.LoginEmailPolicy, Key: byEmail}), oauthHandler.AuthorizeSubmitHandler)
+		oauth.GET("/authorize/webauthn.js", AuthorizeScriptHandler)
+		// Password grants also count against the login limit of their user
+		oauth.POST("/token", clientAuth, RateLimit(limiter, RateLimitRule{Policy: ratelimit.ClientPolicy, Key: byClient}, RateLimitRule{Policy: ratelimit.LoginEmailPolicy, Key: byEmail}), oauthHandler.TokenHandler)
+		oauth.POST("/introspect", clientAuth, clientLimit, oauthHandler.IntrospectHandler)
+		oauth.POST("/revoke", clientAuth, clientLimit, oauthHandler.RevokeHandler)
+	}
+
+	router.GET("/.well-known/openid-configuration", OpenIDConfigurationHandler)
+	router.GET("/userinfo", UserInfoMiddleware(), oauthHandler.UserInfoHandler)
+	router.POST("/userinfo", UserInfoMiddleware(), oauthHandler.UserInfoHandler)
+}
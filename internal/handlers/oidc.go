@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenIDConfigurationHandler publishes the OpenID Connect discovery document
+func OpenIDConfigurationHandler(c *gin.Context) {
+	issuer := utils.Issuer()
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, models.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		ScopesSupported:                   utils.SupportedScopes,
+		ResponseTypesSupported:            []string{service.ResponseTypeCode},
+		GrantTypesSupported:               []string{service.GrantTypeAuthorizationCode, service.GrantTypePassword, service.GrantTypeRefreshToken, service.GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  utils.SigningAlgorithms(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{service.CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp", "email", "email_verified", "name", "preferred_username", "role"},
+	})
+}
+
+// UserInfoHandler returns the claims about the authenticated user allowed by the token's scopes
+func (h *OAuthHandler) UserInfoHandler(c *gin.Context) {
+	claims := currentClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	userInfo, err := h.authService.UserInfo(claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInsufficientScope):
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Token does not have the openid scope"})
+		case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrInvalidToken):
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid or expired token"})
+		default:
+			log.Printf("Error loading user info: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load user info"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, userInfo)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPasswordHandler sends a password reset link.
+// The response is the same whether or not the address belongs to an account.
+func (h *AuthHandler) ForgotPasswordHandler(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	if err := h.authService.ForgotPassword(req.Email); err != nil {
+		if errors.Is(err, service.ErrInvalidArgument) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+			return
+		}
+		// Failures are not reported, they would tell that the account exists
+		log.Printf("Error processing password reset request: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, models.MessageResponse{Message: "If the address belongs to an account, a password reset link has been sent"})
+}
+
+// ResetPasswordHandler sets a new password with the token from a reset link
+func (h *AuthHandler) ResetPasswordHandler(c *gin.Context) {
+	var req models.ResetPasswordRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	if err := h.authService.ResetPassword(req.Token, req.NewPassword, requestInfo(c)); err != nil {
+		if passwordPolicyError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidArgument):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid or expired reset link"})
+		default:
+			log.Printf("Error resetting password: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to reset password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Password has been reset, please log in again"})
+}
+
+// passwordPolicyError responds with every password policy violation if err carries them
+func passwordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *service.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	violations := make([]models.PasswordViolation, len(policyErr.Violations))
+	for i, violation := range policyErr.Violations {
+		violations[i] = models.PasswordViolation{Code: violation.Code, Message: violation.Message}
+	}
+	c.JSON(http.StatusBadRequest, models.PasswordPolicyErrorResponse{
+		Error:      "Password does not meet the password policy",
+		Violations: violations,
+	})
+	return true
+}
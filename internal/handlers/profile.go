@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// MeHandler returns the profile of the current user
+func (h *AuthHandler) MeHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	user, err := h.authService.GetUser(userID)
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateMeHandler changes the editable fields of the current user's profile
+func (h *AuthHandler) UpdateMeHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req models.UpdateProfileRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	user, err := h.authService.UpdateProfile(userID, service.ProfileUpdate{
+		Name:            req.Name,
+		Email:           req.Email,
+		CurrentPassword: req.CurrentPassword,
+	}, requestInfo(c))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePasswordHandler replaces the current user's password after checking the current one
+func (h *AuthHandler) ChangePasswordHandler(c *gin.Context) {
+	claims := currentClaims(c)
+	if _, ok := currentUserID(c); !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req models.ChangePasswordRequest
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	if err := h.authService.ChangePassword(claims, req.CurrentPassword, req.NewPassword, req.SignOutOtherSessions, requestInfo(c)); err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Password changed"})
+}
+
+// profileError maps account management service errors to HTTP responses
+func profileError(c *gin.Context, err error) {
+	if passwordPolicyError(c, err) || tooManyAttempts(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Current password is incorrect"})
+	case errors.Is(err, service.ErrUserExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "User with this email already exists"})
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+	default:
+		log.Printf("Error managing account: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limit policies of the HTTP API; RATE_LIMIT_<NAME> overrides each of them, "off" disables it
+var (
+	// httpPolicy applies to every request from one address
+	httpPolicy = ratelimit.Policy{Name: "http", Limit: 1200, Period: time.Minute}
+	// mailIPPolicy and mailEmailPolicy limit requests that send email
+	mailIPPolicy    = ratelimit.Policy{Name: "mail_ip", Limit: 20, Period: time.Hour}
+	mailEmailPolicy = ratelimit.Policy{Name: "mail_email", Limit: 5, Period: time.Hour}
+)
+
+// maxPeekedBody is the largest request body read to find the email to limit by
+const maxPeekedBody = 64 << 10
+
+// RateLimitRule applies a policy to the bucket named by Key; requests without a key are not limited by it
+type RateLimitRule struct {
+	Policy ratelimit.Policy
+	Key    func(c *gin.Context) string
+}
+
+// GlobalRateLimit limits all requests from one address; install it before any route
+func GlobalRateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return RateLimit(limiter, RateLimitRule{Policy: httpPolicy, Key: byIP})
+}
+
+// RateLimit rejects requests with 429 once any of the rules runs out of tokens.
+// The X-RateLimit-* headers describe the rule closest to its limit.
+func RateLimit(limiter *ratelimit.Limiter, rules ...RateLimitRule) gin.HandlerFunc {
+	for i := range rules {
+		rules[i].Policy = ratelimit.PolicyFromEnv(rules[i].Policy)
+	}
+
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		results := make([]ratelimit.Result, 0, len(rules))
+		for _, rule := range rules {
+			if !rule.Policy.Enabled() {
+				continue
+			}
+			if key := rule.Key(c); key != "" {
+				results = append(results, limiter.Take(rule.Policy, key))
+			}
+		}
+
+		result, ok := ratelimit.Strictest(results)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{Error: "Rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// byIP keys a rule by the client address
+func byIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// byEmail keys a rule by the email in a JSON body, the username of an OAuth form
+// or the email field of the login page
+func byEmail(c *gin.Context) string {
+	if strings.HasPrefix(c.ContentType(), "application/x-www-form-urlencoded") {
+		if username := c.PostForm("username"); username != "" {
+			return strings.ToLower(username)
+		}
+		return strings.ToLower(c.PostForm("email"))
+	}
+
+	// Read the body and put it back for the handler
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekedBody))
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	return strings.ToLower(req.Email)
+}
+
+// byClient keys a rule by the authenticated OAuth client or the subject of the bearer token.
+// Both are only known after ClientAuthMiddleware or AuthMiddleware; unauthenticated requests are not keyed.
+func byClient(c *gin.Context) string {
+	if client := currentClient(c); client != nil {
+		return client.ID
+	}
+	if claims := currentClaims(c); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+// ceilSeconds rounds a duration up to whole seconds for the headers
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
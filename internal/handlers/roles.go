@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RoleHandler handles the administration of roles, permissions and the roles of users
+type RoleHandler struct {
+	authService *service.AuthService
+}
+
+// NewRoleHandler creates a new RoleHandler instance
+func NewRoleHandler(authService *service.AuthService) *RoleHandler {
+	return &RoleHandler{authService: authService}
+}
+
+// ListRolesHandler lists the roles with their permissions
+func (h *RoleHandler) ListRolesHandler(c *gin.Context) {
+	roles, err := h.authService.ListRoles()
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRoleHandler defines a new role
+func (h *RoleHandler) CreateRoleHandler(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	role, err := h.authService.CreateRole(req, requestInfo(c))
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRoleHandler replaces the description and the permissions of a role
+func (h *RoleHandler) UpdateRoleHandler(c *gin.Context) {
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	role, err := h.authService.UpdateRole(c.Param("name"), req, requestInfo(c))
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRoleHandler removes a role and takes it away from every user
+func (h *RoleHandler) DeleteRoleHandler(c *gin.Context) {
+	if err := h.authService.DeleteRole(c.Param("name"), requestInfo(c)); err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Role deleted"})
+}
+
+// ListPermissionsHandler lists the permissions
+func (h *RoleHandler) ListPermissionsHandler(c *gin.Context) {
+	permissions, err := h.authService.ListPermissions()
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// CreatePermissionHandler defines a new permission
+func (h *RoleHandler) CreatePermissionHandler(c *gin.Context) {
+	var req models.CreatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	permission, err := h.authService.CreatePermission(req, requestInfo(c))
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, permission)
+}
+
+// DeletePermissionHandler removes a permission and takes it away from every role
+func (h *RoleHandler) DeletePermissionHandler(c *gin.Context) {
+	if err := h.authService.DeletePermission(c.Param("name"), requestInfo(c)); err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Permission deleted"})
+}
+
+// GetUserRolesHandler returns the roles of a user and the permissions they grant
+func (h *RoleHandler) GetUserRolesHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	access, err := h.authService.GetUserAccess(userID)
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, access)
+}
+
+// SetUserRolesHandler replaces the roles of a user
+func (h *RoleHandler) SetUserRolesHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	var req models.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	access, err := h.authService.SetUserRoles(userID, req.Roles, requestInfo(c))
+	if err != nil {
+		roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, access)
+}
+
+// roleError maps role management service errors to HTTP responses
+func roleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrRoleExists), errors.Is(err, service.ErrPermissionExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Role not found"})
+	case errors.Is(err, service.ErrPermissionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Permission not found"})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+	default:
+		log.Printf("Error managing roles: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListSessionsHandler returns the devices the current user is signed in on
+func (h *AuthHandler) ListSessionsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(userID, currentClaims(c).FamilyID)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessionHandler signs out one of the current user's sessions
+func (h *AuthHandler) RevokeSessionHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Session not found"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(userID, sessionID, requestInfo(c)); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Session revoked"})
+}
+
+// RevokeOtherSessionsHandler signs out every session of the current user except the one making the request
+func (h *AuthHandler) RevokeOtherSessionsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if err := h.authService.RevokeOtherSessions(userID, currentClaims(c).FamilyID, requestInfo(c)); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Other sessions revoked"})
+}
+
+// LoginHistoryHandler returns the recent successful and failed logins of the current user
+func (h *AuthHandler) LoginHistoryHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	limit, ok := historyLimit(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.authService.LoginHistory(userID, limit)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// historyLimit reads the optional limit query parameter of the login history
+func historyLimit(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return 0, true
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "invalid limit"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// sessionError maps session management service errors to HTTP responses
+func sessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Session not found"})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+	default:
+		log.Printf("Error managing sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
@@ -0,0 +1,89 @@
+// Passkey and security key sign-in for the hosted authorization page.
+// The assertion is posted with the form and verified by the server like a password.
+(function () {
+  'use strict';
+
+  var form = document.getElementById('authorize');
+  var button = document.getElementById('webauthn');
+  var error = document.getElementById('webauthn-error');
+  if (!form || !button) {
+    return;
+  }
+  if (!window.PublicKeyCredential || !navigator.credentials) {
+    button.hidden = true;
+    return;
+  }
+
+  function decode(value) {
+    var base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    var binary = atob(base64 + '==='.slice((base64.length + 3) % 4));
+    var bytes = new Uint8Array(binary.length);
+    for (var i = 0; i < binary.length; i++) {
+      bytes[i] = binary.charCodeAt(i);
+    }
+    return bytes;
+  }
+
+  function encode(buffer) {
+    var bytes = new Uint8Array(buffer);
+    var binary = '';
+    for (var i = 0; i < bytes.length; i++) {
+      binary += String.fromCharCode(bytes[i]);
+    }
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function fail() {
+    button.disabled = false;
+    error.textContent = 'Sign-in with a security key failed, please try again';
+    error.hidden = false;
+  }
+
+  button.addEventListener('click', function () {
+    var mfaToken = form.elements.mfa_token ? form.elements.mfa_token.value : '';
+    var url = mfaToken ? '/auth/login/mfa/webauthn/begin' : '/auth/webauthn/login/begin';
+
+    button.disabled = true;
+    error.hidden = true;
+
+    fetch(url, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(mfaToken ? { mfa_token: mfaToken } : {})
+    }).then(function (response) {
+      if (!response.ok) {
+        throw new Error('failed to start WebAuthn ceremony');
+      }
+      return response.json();
+    }).then(function (options) {
+      var publicKey = options.publicKey;
+      publicKey.challenge = decode(publicKey.challenge);
+      publicKey.allowCredentials = (publicKey.allowCredentials || []).map(function (credential) {
+        return { type: credential.type, id: decode(credential.id), transports: credential.transports };
+      });
+
+      return navigator.credentials.get({ publicKey: publicKey }).then(function (credential) {
+        var response = credential.response;
+        form.elements.webauthn_session.value = options.session_id;
+        form.elements.webauthn_credential.value = JSON.stringify({
+          id: credential.id,
+          rawId: encode(credential.rawId),
+          type: credential.type,
+          response: {
+            clientDataJSON: encode(response.clientDataJSON),
+            authenticatorData: encode(response.authenticatorData),
+            signature: encode(response.signature),
+            userHandle: response.userHandle ? encode(response.userHandle) : ''
+          }
+        });
+        // form.submit() sends no submit button, so the consent is added explicitly
+        var allow = document.createElement('input');
+        allow.type = 'hidden';
+        allow.name = 'action';
+        allow.value = 'allow';
+        form.appendChild(allow);
+        form.submit();
+      });
+    }).catch(fail);
+  });
+})();
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UserHandler handles user account administration
+type UserHandler struct {
+	authService *service.AuthService
+}
+
+// NewUserHandler creates a new UserHandler instance
+func NewUserHandler(authService *service.AuthService) *UserHandler {
+	return &UserHandler{authService: authService}
+}
+
+// UnlockUserHandler lifts the login lockout of a user's account
+func (h *UserHandler) UnlockUserHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	if err := h.authService.UnlockUser(userID, requestInfo(c)); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+			return
+		}
+		log.Printf("Error unlocking user: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "User unlocked"})
+}
+
+// ListUserSessionsHandler returns the devices a user is signed in on, for support staff
+func (h *UserHandler) ListUserSessionsHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	if _, err := h.authService.GetUser(userID); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(userID, "")
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeUserSessionHandler signs out one of a user's sessions
+func (h *UserHandler) RevokeUserSessionHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("sid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Session not found"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(userID, sessionID, requestInfo(c)); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Session revoked"})
+}
+
+// UserLoginHistoryHandler returns the recent logins of a user, for support staff
+func (h *UserHandler) UserLoginHistoryHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	limit, ok := historyLimit(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.authService.GetUser(userID); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	history, err := h.authService.LoginHistory(userID, limit)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
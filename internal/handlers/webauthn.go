@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BeginWebAuthnRegistrationHandler returns the options for registering a passkey or security key
+func (h *AuthHandler) BeginWebAuthnRegistrationHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	options, err := h.authService.BeginWebAuthnRegistration(userID)
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnRegistrationHandler verifies the new credential created by the browser and stores it
+func (h *AuthHandler) FinishWebAuthnRegistrationHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req models.WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	credential, err := h.authService.FinishWebAuthnRegistration(userID, &req)
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListWebAuthnCredentialsHandler lists the authenticators registered by the current user
+func (h *AuthHandler) ListWebAuthnCredentialsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	credentials, err := h.authService.ListWebAuthnCredentials(userID)
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+// DeleteWebAuthnCredentialHandler removes one of the current user's authenticators
+func (h *AuthHandler) DeleteWebAuthnCredentialHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Credential not found"})
+		return
+	}
+
+	if err := h.authService.DeleteWebAuthnCredential(userID, id); err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Credential deleted"})
+}
+
+// BeginWebAuthnLoginHandler returns the options for a passwordless login with a passkey
+func (h *AuthHandler) BeginWebAuthnLoginHandler(c *gin.Context) {
+	options, err := h.authService.BeginWebAuthnLogin()
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnLoginHandler verifies a passkey assertion and returns the user's tokens
+func (h *AuthHandler) FinishWebAuthnLoginHandler(c *gin.Context) {
+	var req models.WebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	result, err := h.authService.FinishWebAuthnLogin(req.SessionID, &req.Credential, requestInfo(c))
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(result))
+}
+
+// BeginWebAuthnMFAHandler returns the options for using a security key as the second login step
+func (h *AuthHandler) BeginWebAuthnMFAHandler(c *gin.Context) {
+	var req models.WebAuthnMFABeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	options, err := h.authService.BeginWebAuthnMFA(req.MFAToken)
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnMFAHandler completes a login that requires a second factor with a security key
+func (h *AuthHandler) FinishWebAuthnMFAHandler(c *gin.Context) {
+	var req models.WebAuthnMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	result, err := h.authService.CompleteWebAuthnMFALogin(req.MFAToken, req.SessionID, &req.Credential, requestInfo(c))
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(result))
+}
+
+// webAuthnError maps WebAuthn service errors to HTTP responses
+func webAuthnError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidArgument):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request format"})
+	case errors.Is(err, service.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid or expired login session"})
+	case errors.Is(err, service.ErrInvalidWebAuthnResponse):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "WebAuthn verification failed"})
+	case errors.Is(err, service.ErrMFANotEnrolled):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "No security keys are registered"})
+	case errors.Is(err, service.ErrCredentialExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "This authenticator is already registered"})
+	case errors.Is(err, service.ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Credential not found"})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+	case errors.Is(err, service.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Email address is not verified"})
+	default:
+		log.Printf("Error processing WebAuthn request: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+	}
+}
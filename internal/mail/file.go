@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes every message to its own .eml file in an outbox directory,
+// so that development and test environments can read mail without a mail server
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a new FileMailer instance and the outbox directory
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message to the outbox
+func (m *FileMailer) Send(msg Message) error {
+	data, err := formatMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// Timestamped names keep the outbox in delivery order
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), uuid.New())
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit event types
+const (
+	AuditUserRegister   = "user.register"
+	AuditUserUnlock     = "user.unlock"
+	AuditLogin          = "login"
+	AuditTokenRefresh   = "token.refresh"
+	AuditTokenRevoke    = "token.revoke"
+	AuditSessionRevoke  = "session.revoke"
+	AuditPasswordChange = "password.change"
+	AuditPasswordReset  = "password.reset"
+	AuditRoleChange     = "role.change"
+)
+
+// Audit event outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is an entry of the append-only audit log.
+// ActorID is whoever performed the action, a user ID or the client ID of a service token;
+// the subject is the account affected by it.
+type AuditEvent struct {
+	ID           int64      `db:"id" json:"id"`
+	Event        string     `db:"event" json:"event"`
+	Outcome      string     `db:"outcome" json:"outcome"`
+	ActorID      string     `db:"actor_id" json:"actor_id,omitempty"`
+	SubjectID    *uuid.UUID `db:"subject_id" json:"subject_id,omitempty"`
+	SubjectEmail string     `db:"subject_email" json:"subject_email,omitempty"`
+	ClientID     string     `db:"client_id" json:"client_id,omitempty"`
+	IP           string     `db:"ip" json:"ip,omitempty"`
+	UserAgent    string     `db:"user_agent" json:"user_agent,omitempty"`
+	Reason       string     `db:"reason" json:"reason,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// AuditEventFilter selects audit events; zero fields match everything.
+// Results are ordered newest first and Before continues a previous page.
+type AuditEventFilter struct {
+	Event     string
+	Outcome   string
+	ActorID   string
+	SubjectID *uuid.UUID
+	IP        string
+	From      *time.Time
+	To        *time.Time
+	Before    int64
+	Limit     int
+}
+
+// AuditEventsResponse is a page of audit events.
+// NextBefore is passed as before to fetch the next page; it is omitted on the last page.
+type AuditEventsResponse struct {
+	Events     []AuditEvent `json:"events"`
+	NextBefore int64        `json:"next_before,omitempty"`
+}
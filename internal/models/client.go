@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OAuthClient represents an application registered to call the OAuth endpoints.
+// Public clients such as SPAs and mobile apps have no secret and must use PKCE.
+// AllowedScopes and Audience limit the machine tokens of the client credentials grant.
+type OAuthClient struct {
+	ID            string         `db:"id" json:"client_id"`
+	Name          string         `db:"name" json:"name"`
+	SecretHash    string         `db:"secret_hash" json:"-"`
+	RedirectURIs  pq.StringArray `db:"redirect_uris" json:"redirect_uris"`
+	Public        bool           `db:"public" json:"public"`
+	AllowedScopes pq.StringArray `db:"allowed_scopes" json:"allowed_scopes"`
+	Audience      string         `db:"audience" json:"audience,omitempty"`
+	CreatedAt     time.Time      `db:"created_at" json:"created_at"`
+}
+
+// CreateClientRequest is the request structure for registering an OAuth client
+type CreateClientRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	RedirectURIs  []string `json:"redirect_uris"`
+	Public        bool     `json:"public"`
+	AllowedScopes []string `json:"allowed_scopes"`
+	Audience      string   `json:"audience"`
+}
+
+// CreateClientResponse is returned once when a client is registered; the secret is not stored in plain text
+type CreateClientResponse struct {
+	ClientID      string    `json:"client_id"`
+	ClientSecret  string    `json:"client_secret,omitempty"`
+	Name          string    `json:"name"`
+	RedirectURIs  []string  `json:"redirect_uris"`
+	Public        bool      `json:"public"`
+	AllowedScopes []string  `json:"allowed_scopes"`
+	Audience      string    `json:"audience,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// IntrospectionResponse is the RFC 7662 token introspection response
+type IntrospectionResponse struct {
+	Active      bool     `json:"active"`
+	Scope       string   `json:"scope,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	TokenType   string   `json:"token_type,omitempty"`
+	Exp         int64    `json:"exp,omitempty"`
+	Iat         int64    `json:"iat,omitempty"`
+	Sub         string   `json:"sub,omitempty"`
+	Jti         string   `json:"jti,omitempty"`
+	Role        string   `json:"role,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+	Aud         []string `json:"aud,omitempty"`
+	SubType     string   `json:"sub_type,omitempty"`
+	AMR         []string `json:"amr,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// OAuthErrorResponse is the RFC 6749 error response format
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// OAuthTokenResponse is the RFC 6749 token endpoint response
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// AuthorizationCode is a pending authorization code grant; only a hash of the code is stored
+type AuthorizationCode struct {
+	CodeHash            string         `db:"code_hash"`
+	ClientID            string         `db:"client_id"`
+	UserID              uuid.UUID      `db:"user_id"`
+	RedirectURI         string         `db:"redirect_uri"`
+	Scope               string         `db:"scope"`
+	Nonce               string         `db:"nonce"`
+	CodeChallenge       string         `db:"code_challenge"`
+	CodeChallengeMethod string         `db:"code_challenge_method"`
+	AuthTime            time.Time      `db:"auth_time"`
+	ExpiresAt           time.Time      `db:"expires_at"`
+	UsedAt              *time.Time     `db:"used_at"`
+	FamilyID            *uuid.UUID     `db:"family_id"`
+	AMR                 pq.StringArray `db:"amr"`
+	CreatedAt           time.Time      `db:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// SigningKey represents a token signing key persisted in the key store
+type SigningKey struct {
+	ID          string     `db:"id" json:"id"`
+	Algorithm   string     `db:"algorithm" json:"algorithm"`
+	KeyMaterial string     `db:"key_material" json:"-"`
+	Status      string     `db:"status" json:"status"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	RetiredAt   *time.Time `db:"retired_at" json:"retired_at,omitempty"`
+}
+
+// SigningKeyInfo describes a signing key without its secret material
+type SigningKeyInfo struct {
+	ID        string     `json:"kid"`
+	Algorithm string     `json:"algorithm"`
+	Status    string     `json:"status"`
+	Static    bool       `json:"static"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// GenerateKeyRequest is the request structure for generating a signing key
+type GenerateKeyRequest struct {
+	Algorithm string `json:"algorithm"`
+}
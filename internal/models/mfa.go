@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPCredential is a user's TOTP enrollment; the secret is stored encrypted
+type TOTPCredential struct {
+	UserID      uuid.UUID  `db:"user_id"`
+	Secret      string     `db:"secret"`
+	LastCounter int64      `db:"last_counter"`
+	EnabledAt   *time.Time `db:"enabled_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+}
+
+// TOTPEnrollResponse is returned when TOTP enrollment starts
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFACodeRequest is the request structure for confirming or disabling a second factor
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFAChallengeResponse is returned by the first login step when a second factor is required
+type MFAChallengeResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	ExpiresAt   int64    `json:"expires_at"`
+	Methods     []string `json:"methods"`
+}
+
+// MFALoginRequest is the request structure for the second login step
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// RecoveryCode is a single-use MFA recovery code; only its bcrypt hash is stored
+type RecoveryCode struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	CodeHash  string     `db:"code_hash"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// MFAEnabledResponse is returned when a second factor is activated.
+// Recovery codes are included only when a new set was generated.
+type MFAEnabledResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// RecoveryCodesResponse returns a newly generated set of recovery codes
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// RecoveryCodesStatusResponse reports how many recovery codes are left
+type RecoveryCodesStatusResponse struct {
+	Remaining int `json:"remaining"`
+}
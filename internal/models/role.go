@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Role is a named set of permissions that users can hold
+type Role struct {
+	Name        string         `db:"name" json:"name"`
+	Description string         `db:"description" json:"description"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
+}
+
+// Permission is an action that downstream services authorise by name, such as "reports:read"
+type Permission struct {
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// UserAccess lists the roles of a user and the permissions they grant, both sorted by name
+type UserAccess struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreateRoleRequest is the request structure for defining a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest is the request structure for changing a role; the permissions are replaced
+type UpdateRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreatePermissionRequest is the request structure for defining a permission
+type CreatePermissionRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// SetUserRolesRequest is the request structure for replacing the roles of a user.
+// An empty list removes every role; a missing one is rejected.
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represents an issued refresh token tracked on the server side
+type RefreshToken struct {
+	ID              uuid.UUID  `db:"id" json:"id"`
+	FamilyID        uuid.UUID  `db:"family_id" json:"family_id"`
+	UserID          uuid.UUID  `db:"user_id" json:"user_id"`
+	ExpiresAt       time.Time  `db:"expires_at" json:"expires_at"`
+	RotatedAt       *time.Time `db:"rotated_at" json:"rotated_at,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	FamilyRevokedAt *time.Time `db:"family_revoked_at" json:"family_revoked_at,omitempty"`
+}
+
+// Session is a refresh token family together with the device it was issued to.
+// Revoking the family signs the session out.
+type Session struct {
+	ID         uuid.UUID `db:"id"`
+	UserID     uuid.UUID `db:"user_id"`
+	ClientID   string    `db:"client_id"`
+	DeviceName string    `db:"device_name"`
+	UserAgent  string    `db:"user_agent"`
+	IP         string    `db:"ip"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+}
+
+// SessionInfo describes an active session to its owner
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	ClientID   string    `json:"client_id,omitempty"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// TokenRefreshRequest is the request structure for refreshing tokens
+type TokenRefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenRefreshResponse is the response structure for refreshing tokens
+type TokenRefreshResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// LogoutRequest is the optional request body for logout
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -4,32 +4,38 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // User represents a user in the system
 type User struct {
-	ID           uuid.UUID `db:"id" json:"id"`
-	Email        string    `db:"email" json:"email"`
-	PasswordHash string    `db:"password_hash" json:"-"`
-	Role         string    `db:"role" json:"role"`
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	ID            uuid.UUID  `db:"id" json:"id"`
+	Email         string     `db:"email" json:"email"`
+	EmailVerified bool       `db:"email_verified" json:"email_verified"`
+	Name          string     `db:"name" json:"name"`
+	PasswordHash  string     `db:"password_hash" json:"-"`
+	Role          string     `db:"role" json:"role"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	LastLoginAt   *time.Time `db:"last_login_at" json:"last_login_at,omitempty"`
 }
 
 // UserRegisterRequest is the request structure for user registration
 type UserRegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 }
 
-// UserRegisterResponse is the response structure for user registration
+// UserRegisterResponse is the response structure for user registration.
+// No tokens are issued while the email address still has to be verified.
 type UserRegisterResponse struct {
-	UserID       string    `json:"user_id"`
-	Email        string    `json:"email"`
-	Role         string    `json:"role"`
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    int64     `json:"expires_at"`
-	CreatedAt    time.Time `json:"created_at"`
+	UserID        string    `json:"user_id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	AccessToken   string    `json:"access_token,omitempty"`
+	RefreshToken  string    `json:"refresh_token,omitempty"`
+	ExpiresAt     int64     `json:"expires_at,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // UserLoginRequest is the request structure for user login
@@ -40,16 +46,84 @@ type UserLoginRequest struct {
 
 // UserLoginResponse is the response structure for user login
 type UserLoginResponse struct {
-	UserID       string    `json:"user_id"`
-	Email        string    `json:"email"`
-	Role         string    `json:"role"`
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    int64     `json:"expires_at"`
-	LastLoginAt  time.Time `json:"last_login_at"`
+	UserID       string     `json:"user_id"`
+	Email        string     `json:"email"`
+	Role         string     `json:"role"`
+	AccessToken  string     `json:"access_token"`
+	RefreshToken string     `json:"refresh_token"`
+	ExpiresAt    int64      `json:"expires_at"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+}
+
+// VerifyEmailRequest is the request structure for email verification
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest is the request structure for sending a new verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPasswordRequest is the request structure for requesting a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest is the request structure for setting a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// UpdateProfileRequest is the request structure for editing the current user's profile.
+// Omitted fields are left unchanged; changing the email requires the current password.
+type UpdateProfileRequest struct {
+	Name            *string `json:"name"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordRequest is the request structure for changing the current user's password
+type ChangePasswordRequest struct {
+	CurrentPassword      string `json:"current_password" binding:"required"`
+	NewPassword          string `json:"new_password" binding:"required"`
+	SignOutOtherSessions bool   `json:"sign_out_other_sessions"`
 }
 
 // ErrorResponse is a generic error response format
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
+
+// PasswordPolicyErrorResponse is returned when a new password breaks the password policy
+type PasswordPolicyErrorResponse struct {
+	Error      string              `json:"error"`
+	Violations []PasswordViolation `json:"violations"`
+}
+
+// PasswordViolation is one password policy rule a new password breaks
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// MessageResponse is a generic success response format
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// LoginHistoryEntry is one successful or failed login of a user.
+// Methods lists the factors of a successful login; Reason says why a login failed.
+type LoginHistoryEntry struct {
+	ID         int64          `db:"id" json:"id"`
+	UserID     uuid.UUID      `db:"user_id" json:"-"`
+	Success    bool           `db:"success" json:"success"`
+	Methods    pq.StringArray `db:"methods" json:"methods,omitempty"`
+	Reason     string         `db:"reason" json:"reason,omitempty"`
+	ClientID   string         `db:"client_id" json:"client_id,omitempty"`
+	DeviceName string         `db:"device_name" json:"device_name,omitempty"`
+	UserAgent  string         `db:"user_agent" json:"user_agent,omitempty"`
+	IP         string         `db:"ip" json:"ip,omitempty"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+}
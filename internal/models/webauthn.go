@@ -0,0 +1,159 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user
+type WebAuthnCredential struct {
+	ID           uuid.UUID      `db:"id"`
+	UserID       uuid.UUID      `db:"user_id"`
+	CredentialID []byte         `db:"credential_id"`
+	PublicKey    []byte         `db:"public_key"`
+	SignCount    int64          `db:"sign_count"`
+	AAGUID       []byte         `db:"aaguid"`
+	Name         string         `db:"name"`
+	Transports   pq.StringArray `db:"transports"`
+	CreatedAt    time.Time      `db:"created_at"`
+	LastUsedAt   *time.Time     `db:"last_used_at"`
+}
+
+// WebAuthnSession holds the challenge of a WebAuthn ceremony until the browser responds.
+// UserID is nil for passkey logins, where the user is only known from the credential.
+type WebAuthnSession struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    *uuid.UUID `db:"user_id"`
+	Ceremony  string     `db:"ceremony"`
+	Challenge string     `db:"challenge"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// WebAuthnCredentialInfo describes a registered authenticator to its owner
+type WebAuthnCredentialInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Transports []string   `json:"transports"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnRegisterResponse describes a newly registered authenticator.
+// Recovery codes are included when the authenticator is the user's first second factor.
+type WebAuthnRegisterResponse struct {
+	WebAuthnCredentialInfo
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// RelyingParty identifies the service to the authenticator
+type RelyingParty struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUser identifies the account a new credential belongs to
+type WebAuthnUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter names a public key algorithm the service accepts
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states the requirements for the authenticator of a new credential
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PublicKeyCredentialCreationOptions are passed to navigator.credentials.create().
+// Binary values are base64url encoded as in PublicKeyCredential.parseCreationOptionsFromJSON().
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   WebAuthnUser           `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions are passed to navigator.credentials.get()
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// WebAuthnRegistrationOptions starts a registration ceremony
+type WebAuthnRegistrationOptions struct {
+	SessionID string                             `json:"session_id"`
+	PublicKey PublicKeyCredentialCreationOptions `json:"publicKey"`
+}
+
+// WebAuthnLoginOptions starts an authentication ceremony
+type WebAuthnLoginOptions struct {
+	SessionID string                            `json:"session_id"`
+	PublicKey PublicKeyCredentialRequestOptions `json:"publicKey"`
+}
+
+// AuthenticatorResponse is the response part of a PublicKeyCredential serialized with toJSON().
+// Registration fills AttestationObject; authentication fills AuthenticatorData, Signature and UserHandle.
+type AuthenticatorResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject,omitempty"`
+	Transports        []string `json:"transports,omitempty"`
+	AuthenticatorData string   `json:"authenticatorData,omitempty"`
+	Signature         string   `json:"signature,omitempty"`
+	UserHandle        string   `json:"userHandle,omitempty"`
+}
+
+// PublicKeyCredential is the result of a WebAuthn ceremony in the browser
+type PublicKeyCredential struct {
+	ID       string                `json:"id" binding:"required"`
+	RawID    string                `json:"rawId"`
+	Type     string                `json:"type" binding:"required"`
+	Response AuthenticatorResponse `json:"response" binding:"required"`
+}
+
+// WebAuthnRegisterRequest is the request structure for finishing a registration
+type WebAuthnRegisterRequest struct {
+	SessionID  string              `json:"session_id" binding:"required"`
+	Name       string              `json:"name"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+// WebAuthnLoginRequest is the request structure for finishing a passkey login
+type WebAuthnLoginRequest struct {
+	SessionID  string              `json:"session_id" binding:"required"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+// WebAuthnMFABeginRequest starts a security key check as the second login step
+type WebAuthnMFABeginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// WebAuthnMFARequest is the request structure for finishing the second login step with a security key
+type WebAuthnMFARequest struct {
+	MFAToken   string              `json:"mfa_token" binding:"required"`
+	SessionID  string              `json:"session_id" binding:"required"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
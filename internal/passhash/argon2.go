@@ -0,0 +1,103 @@
+package passhash
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id hashes passwords with argon2id (RFC 9106)
+type Argon2id struct {
+	// Memory is the memory cost in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2id returns argon2id with 64 MiB of memory, 3 iterations and 2 lanes
+func DefaultArgon2id() *Argon2id {
+	return &Argon2id{Memory: 64 * 1024, Iterations: 3, Parallelism: 2}
+}
+
+// argon2idFromEnv reads the PASSWORD_ARGON2_* settings
+func argon2idFromEnv() (*Argon2id, error) {
+	a := DefaultArgon2id()
+
+	memory, err := envInt("PASSWORD_ARGON2_MEMORY", int(a.Memory))
+	if err != nil {
+		return nil, err
+	}
+	iterations, err := envInt("PASSWORD_ARGON2_ITERATIONS", int(a.Iterations))
+	if err != nil {
+		return nil, err
+	}
+	parallelism, err := envInt("PASSWORD_ARGON2_PARALLELISM", int(a.Parallelism))
+	if err != nil {
+		return nil, err
+	}
+
+	if parallelism < 1 || parallelism > math.MaxUint8 {
+		return nil, fmt.Errorf("PASSWORD_ARGON2_PARALLELISM must be between 1 and %d", math.MaxUint8)
+	}
+	if iterations < 1 || iterations > math.MaxUint32 {
+		return nil, fmt.Errorf("PASSWORD_ARGON2_ITERATIONS must be positive")
+	}
+	if memory < 8*parallelism || memory > math.MaxUint32 {
+		return nil, fmt.Errorf("PASSWORD_ARGON2_MEMORY must be at least 8 KiB per lane")
+	}
+
+	return &Argon2id{Memory: uint32(memory), Iterations: uint32(iterations), Parallelism: uint8(parallelism)}, nil
+}
+
+// Name returns "argon2id"
+func (a *Argon2id) Name() string {
+	return AlgorithmArgon2id
+}
+
+// Hash hashes a password into a PHC string
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, keyLength)
+	return phcHash{
+		id:      AlgorithmArgon2id,
+		version: argon2.Version,
+		params:  map[string]int{"m": int(a.Memory), "t": int(a.Iterations), "p": int(a.Parallelism)},
+		salt:    salt,
+		key:     key,
+	}.String("m", "t", "p"), nil
+}
+
+// Verify checks a password against an argon2id PHC string
+func (a *Argon2id) Verify(password, encoded string) error {
+	p, err := parsePHC(encoded, AlgorithmArgon2id, "m", "t", "p")
+	if err != nil {
+		return err
+	}
+	if p.version != argon2.Version || p.params["p"] < 1 || p.params["p"] > math.MaxUint8 ||
+		p.params["t"] < 1 || p.params["t"] > math.MaxUint32 || p.params["m"] > math.MaxUint32 {
+		return ErrUnknownFormat
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt,
+		uint32(p.params["t"]), uint32(p.params["m"]), uint8(p.params["p"]), uint32(len(p.key)))
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether the hash uses less memory, fewer iterations or fewer lanes than configured
+func (a *Argon2id) NeedsRehash(encoded string) bool {
+	p, err := parsePHC(encoded, AlgorithmArgon2id, "m", "t", "p")
+	if err != nil {
+		return true
+	}
+	return p.params["m"] < int(a.Memory) || p.params["t"] < int(a.Iterations) ||
+		p.params["p"] < int(a.Parallelism) || len(p.key) < keyLength
+}
@@ -0,0 +1,60 @@
+package passhash
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt hashes passwords with bcrypt.
+// Its hashes keep the standard $2a$ format that predates PHC strings, so existing hashes stay readable.
+type Bcrypt struct {
+	Cost int
+}
+
+// DefaultBcrypt returns bcrypt with the library's default cost
+func DefaultBcrypt() *Bcrypt {
+	return &Bcrypt{Cost: bcrypt.DefaultCost}
+}
+
+// bcryptFromEnv reads PASSWORD_BCRYPT_COST
+func bcryptFromEnv() (*Bcrypt, error) {
+	cost, err := envInt("PASSWORD_BCRYPT_COST", bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("PASSWORD_BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &Bcrypt{Cost: cost}, nil
+}
+
+// Name returns "bcrypt"
+func (b *Bcrypt) Name() string {
+	return AlgorithmBcrypt
+}
+
+// Hash hashes a password
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify checks a password against a bcrypt hash
+func (b *Bcrypt) Verify(password, encoded string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return err
+}
+
+// NeedsRehash reports whether the hash uses a lower cost than configured
+func (b *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < b.Cost
+}
@@ -0,0 +1,226 @@
+package passhash
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Hash algorithms selected with PASSWORD_HASH_ALGORITHM
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// saltLength and keyLength are the sizes of generated salts and derived keys in bytes
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// ErrMismatch is returned when a password does not match its hash
+var ErrMismatch = errors.New("password does not match")
+
+// ErrUnknownFormat is returned for hashes of an unsupported algorithm
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Algorithm hashes passwords with one hash function.
+// Verify reads the parameters from the stored hash, so it also accepts hashes made with other settings.
+type Algorithm interface {
+	// Name is the algorithm identifier in the stored hash, such as "argon2id"
+	Name() string
+	Hash(password string) (string, error)
+	Verify(password, encoded string) error
+	// NeedsRehash reports whether a hash of this algorithm was made with weaker parameters than configured
+	NeedsRehash(encoded string) bool
+}
+
+// Hasher hashes new passwords with the configured algorithm and verifies hashes of every supported one
+type Hasher struct {
+	current    Algorithm
+	algorithms map[string]Algorithm
+}
+
+// NewHasher creates a new Hasher that hashes with the given algorithm
+func NewHasher(current Algorithm) *Hasher {
+	h := &Hasher{
+		current: current,
+		algorithms: map[string]Algorithm{
+			AlgorithmArgon2id: DefaultArgon2id(),
+			AlgorithmScrypt:   DefaultScrypt(),
+			AlgorithmBcrypt:   DefaultBcrypt(),
+		},
+	}
+	h.algorithms[current.Name()] = current
+	return h
+}
+
+// NewHasherFromEnv creates the hasher selected by PASSWORD_HASH_ALGORITHM; argon2id is the default
+func NewHasherFromEnv() (*Hasher, error) {
+	var current Algorithm
+	var err error
+
+	switch algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm {
+	case AlgorithmArgon2id, "":
+		current, err = argon2idFromEnv()
+	case AlgorithmScrypt:
+		current, err = scryptFromEnv()
+	case AlgorithmBcrypt:
+		current, err = bcryptFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHasher(current), nil
+}
+
+// Algorithm returns the name of the algorithm new hashes are made with
+func (h *Hasher) Algorithm() string {
+	return h.current.Name()
+}
+
+// Hash hashes a password with the configured algorithm
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify checks a password against a stored hash of any supported algorithm.
+// rehash is true when the password matched but the hash should be replaced by one from Hash.
+func (h *Hasher) Verify(password, encoded string) (rehash bool, err error) {
+	algorithm, ok := h.algorithms[algorithmName(encoded)]
+	if !ok {
+		return false, ErrUnknownFormat
+	}
+
+	if err := algorithm.Verify(password, encoded); err != nil {
+		return false, err
+	}
+
+	if algorithm != h.current {
+		return true, nil
+	}
+	return h.current.NeedsRehash(encoded), nil
+}
+
+// algorithmName returns the algorithm identifier at the start of a stored hash
+func algorithmName(encoded string) string {
+	fields := strings.Split(encoded, "$")
+	if len(fields) < 2 || fields[0] != "" {
+		return ""
+	}
+
+	// bcrypt hashes use the older modular crypt format with the version as identifier
+	switch fields[1] {
+	case "2a", "2b", "2y":
+		return AlgorithmBcrypt
+	}
+	return fields[1]
+}
+
+// phcHash is a hash in the PHC string format: $<id>[$v=<version>]$<param>=<value>,...$<salt>$<hash>
+type phcHash struct {
+	id      string
+	version int
+	params  map[string]int
+	salt    []byte
+	key     []byte
+}
+
+// String encodes the hash; params are written in the given order
+func (p phcHash) String(order ...string) string {
+	params := make([]string, 0, len(order))
+	for _, name := range order {
+		params = append(params, name+"="+strconv.Itoa(p.params[name]))
+	}
+
+	fields := []string{"", p.id}
+	if p.version != 0 {
+		fields = append(fields, "v="+strconv.Itoa(p.version))
+	}
+	fields = append(fields,
+		strings.Join(params, ","),
+		base64.RawStdEncoding.EncodeToString(p.salt),
+		base64.RawStdEncoding.EncodeToString(p.key),
+	)
+	return strings.Join(fields, "$")
+}
+
+// parsePHC decodes a PHC string of the given algorithm; all of the named params must be present
+func parsePHC(encoded, id string, required ...string) (phcHash, error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) < 5 || fields[0] != "" || fields[1] != id {
+		return phcHash{}, ErrUnknownFormat
+	}
+
+	p := phcHash{id: id, params: make(map[string]int)}
+	rest := fields[2:]
+	if strings.HasPrefix(rest[0], "v=") {
+		version, err := strconv.Atoi(strings.TrimPrefix(rest[0], "v="))
+		if err != nil {
+			return phcHash{}, ErrUnknownFormat
+		}
+		p.version = version
+		rest = rest[1:]
+	}
+	if len(rest) != 3 {
+		return phcHash{}, ErrUnknownFormat
+	}
+
+	for _, param := range strings.Split(rest[0], ",") {
+		name, value, found := strings.Cut(param, "=")
+		if !found {
+			return phcHash{}, ErrUnknownFormat
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return phcHash{}, ErrUnknownFormat
+		}
+		p.params[name] = number
+	}
+	for _, name := range required {
+		if _, ok := p.params[name]; !ok {
+			return phcHash{}, ErrUnknownFormat
+		}
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(rest[1]); err != nil {
+		return phcHash{}, ErrUnknownFormat
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(rest[2]); err != nil || len(p.key) == 0 {
+		return phcHash{}, ErrUnknownFormat
+	}
+
+	return p, nil
+}
+
+// newSalt generates a random salt
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// envInt reads a numeric setting, falling back to def when the variable is not set
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", name, err)
+	}
+	return number, nil
+}
@@ -0,0 +1,257 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPassword = "correct horse battery staple"
+
+// legacyBcryptHash is a $2a$ hash of testPassword as stored before argon2id became the default
+const legacyBcryptHash = "$2a$04$7QIY/QnHQYjfnSIsFPI2b.CLQYSMePGM0VPF6QMkBw0c9.68J03VS"
+
+// Cheap parameters keep the tests fast; only their relation to each other matters
+func testArgon2id() *Argon2id { return &Argon2id{Memory: 64, Iterations: 2, Parallelism: 2} }
+func testScrypt() *Scrypt     { return &Scrypt{N: 32, R: 8, P: 2} }
+func testBcrypt() *Bcrypt     { return &Bcrypt{Cost: 5} }
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		algorithm Algorithm
+		prefix    string
+	}{
+		{algorithm: testArgon2id(), prefix: "$argon2id$v=19$m=64,t=2,p=2$"},
+		{algorithm: testScrypt(), prefix: "$scrypt$ln=5,r=8,p=2$"},
+		{algorithm: testBcrypt(), prefix: "$2a$05$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm.Name(), func(t *testing.T) {
+			encoded, err := tt.algorithm.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(encoded, tt.prefix) {
+				t.Errorf("hash %q does not start with %q", encoded, tt.prefix)
+			}
+			if algorithmName(encoded) != tt.algorithm.Name() {
+				t.Errorf("algorithmName(%q) = %q", encoded, algorithmName(encoded))
+			}
+
+			if err := tt.algorithm.Verify(testPassword, encoded); err != nil {
+				t.Errorf("Verify with the right password: %v", err)
+			}
+			if err := tt.algorithm.Verify("wrong password", encoded); !errors.Is(err, ErrMismatch) {
+				t.Errorf("Verify with a wrong password = %v, want ErrMismatch", err)
+			}
+			if tt.algorithm.NeedsRehash(encoded) {
+				t.Error("NeedsRehash of a fresh hash = true")
+			}
+
+			// Salts are random, so hashing twice gives different strings
+			again, err := tt.algorithm.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again == encoded {
+				t.Error("two hashes of the same password are equal")
+			}
+		})
+	}
+}
+
+func TestHasherVerify(t *testing.T) {
+	argon2id := testArgon2id()
+	hasher := NewHasher(argon2id)
+
+	current, err := hasher.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scryptHash, err := testScrypt().Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker, err := (&Argon2id{Memory: 32, Iterations: 2, Parallelism: 2}).Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		encoded    string
+		wantRehash bool
+		wantErr    error
+	}{
+		{name: "current algorithm", password: testPassword, encoded: current},
+		{name: "current algorithm, weaker params", password: testPassword, encoded: weaker, wantRehash: true},
+		{name: "legacy bcrypt", password: testPassword, encoded: legacyBcryptHash, wantRehash: true},
+		{name: "legacy bcrypt, wrong password", password: "wrong password", encoded: legacyBcryptHash, wantErr: ErrMismatch},
+		{name: "other algorithm", password: testPassword, encoded: scryptHash, wantRehash: true},
+		{name: "other algorithm, wrong password", password: "wrong password", encoded: scryptHash, wantErr: ErrMismatch},
+		{name: "unknown algorithm", password: testPassword, encoded: "$pbkdf2-sha256$i=1000$c2FsdA$a2V5", wantErr: ErrUnknownFormat},
+		{name: "not a hash", password: testPassword, encoded: "plaintext", wantErr: ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rehash, err := hasher.Verify(tt.password, tt.encoded)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if rehash != tt.wantRehash {
+				t.Errorf("rehash = %v, want %v", rehash, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestParsePHC(t *testing.T) {
+	tests := []struct {
+		name        string
+		encoded     string
+		wantVersion int
+		wantParams  map[string]int
+		wantErr     bool
+	}{
+		{name: "with version", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5", wantVersion: 19, wantParams: map[string]int{"m": 64, "t": 2, "p": 2}},
+		{name: "without version", encoded: "$argon2id$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5", wantParams: map[string]int{"m": 64, "t": 2, "p": 2}},
+		{name: "extra params", encoded: "$argon2id$v=19$m=64,t=2,p=2,x=1$c2FsdHNhbHQ$a2V5a2V5", wantVersion: 19, wantParams: map[string]int{"m": 64, "t": 2, "p": 2, "x": 1}},
+		{name: "missing param", encoded: "$argon2id$v=19$m=64,t=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "empty params", encoded: "$argon2id$v=19$$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "param without value", encoded: "$argon2id$v=19$m=64,t,p=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "non-numeric param", encoded: "$argon2id$v=19$m=64,t=two,p=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "negative param", encoded: "$argon2id$v=19$m=64,t=-2,p=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "non-numeric version", encoded: "$argon2id$v=x$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "bad salt base64", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2Fsd*Nhb$a2V5a2V5", wantErr: true},
+		{name: "bad key base64", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5!2V5", wantErr: true},
+		{name: "padded base64", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdA==$a2V5a2V5", wantErr: true},
+		{name: "empty key", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$", wantErr: true},
+		{name: "missing key", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ", wantErr: true},
+		{name: "extra field", encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5$a2V5", wantErr: true},
+		{name: "other algorithm", encoded: "$scrypt$ln=5,r=8,p=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+		{name: "no leading separator", encoded: "argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePHC(tt.encoded, AlgorithmArgon2id, "m", "t", "p")
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownFormat) {
+					t.Fatalf("parsePHC(%q) error = %v, want ErrUnknownFormat", tt.encoded, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", p.version, tt.wantVersion)
+			}
+			if len(p.params) != len(tt.wantParams) {
+				t.Errorf("params = %v, want %v", p.params, tt.wantParams)
+			}
+			for name, value := range tt.wantParams {
+				if p.params[name] != value {
+					t.Errorf("param %s = %d, want %d", name, p.params[name], value)
+				}
+			}
+			if string(p.salt) != "saltsalt" || string(p.key) != "keykey" {
+				t.Errorf("salt, key = %q, %q", p.salt, p.key)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		encoded   string
+	}{
+		{name: "argon2id p=0", algorithm: testArgon2id(), encoded: "$argon2id$v=19$m=64,t=2,p=0$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "argon2id t=0", algorithm: testArgon2id(), encoded: "$argon2id$v=19$m=64,t=0,p=2$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "argon2id lanes over 255", algorithm: testArgon2id(), encoded: "$argon2id$v=19$m=64,t=2,p=256$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "argon2id other version", algorithm: testArgon2id(), encoded: "$argon2id$v=16$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "argon2id missing version", algorithm: testArgon2id(), encoded: "$argon2id$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "argon2id missing params", algorithm: testArgon2id(), encoded: "$argon2id$v=19$m=64$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "argon2id bad base64", algorithm: testArgon2id(), encoded: "$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5#2V5"},
+		{name: "scrypt p=0", algorithm: testScrypt(), encoded: "$scrypt$ln=5,r=8,p=0$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "scrypt r=0", algorithm: testScrypt(), encoded: "$scrypt$ln=5,r=0,p=2$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "scrypt ln=0", algorithm: testScrypt(), encoded: "$scrypt$ln=0,r=8,p=2$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "scrypt ln too large", algorithm: testScrypt(), encoded: "$scrypt$ln=31,r=8,p=2$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "scrypt missing params", algorithm: testScrypt(), encoded: "$scrypt$ln=5,r=8$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "scrypt bad base64", algorithm: testScrypt(), encoded: "$scrypt$ln=5,r=8,p=2$c2Fsd#Nhb$a2V5a2V5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.algorithm.Verify(testPassword, tt.encoded); !errors.Is(err, ErrUnknownFormat) {
+				t.Errorf("Verify(%q) = %v, want ErrUnknownFormat", tt.encoded, err)
+			}
+		})
+	}
+
+	if err := testBcrypt().Verify(testPassword, "$2a$05$tooshort"); err == nil || errors.Is(err, ErrMismatch) {
+		t.Errorf("bcrypt Verify of a truncated hash = %v, want a format error", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hashWith := func(t *testing.T, algorithm Algorithm) string {
+		t.Helper()
+		encoded, err := algorithm.Hash(testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		hashed    Algorithm
+		want      bool
+	}{
+		{name: "argon2id current", algorithm: testArgon2id(), hashed: testArgon2id()},
+		{name: "argon2id stronger", algorithm: testArgon2id(), hashed: &Argon2id{Memory: 128, Iterations: 3, Parallelism: 2}},
+		{name: "argon2id less memory", algorithm: testArgon2id(), hashed: &Argon2id{Memory: 32, Iterations: 2, Parallelism: 2}, want: true},
+		{name: "argon2id fewer iterations", algorithm: testArgon2id(), hashed: &Argon2id{Memory: 64, Iterations: 1, Parallelism: 2}, want: true},
+		{name: "argon2id fewer lanes", algorithm: testArgon2id(), hashed: &Argon2id{Memory: 64, Iterations: 2, Parallelism: 1}, want: true},
+		{name: "scrypt current", algorithm: testScrypt(), hashed: testScrypt()},
+		{name: "scrypt stronger", algorithm: testScrypt(), hashed: &Scrypt{N: 64, R: 8, P: 2}},
+		{name: "scrypt lower N", algorithm: testScrypt(), hashed: &Scrypt{N: 16, R: 8, P: 2}, want: true},
+		{name: "scrypt lower r", algorithm: testScrypt(), hashed: &Scrypt{N: 32, R: 4, P: 2}, want: true},
+		{name: "scrypt lower p", algorithm: testScrypt(), hashed: &Scrypt{N: 32, R: 8, P: 1}, want: true},
+		{name: "bcrypt current", algorithm: testBcrypt(), hashed: testBcrypt()},
+		{name: "bcrypt stronger", algorithm: testBcrypt(), hashed: &Bcrypt{Cost: 6}},
+		{name: "bcrypt lower cost", algorithm: testBcrypt(), hashed: &Bcrypt{Cost: 4}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := hashWith(t, tt.hashed)
+			if got := tt.algorithm.NeedsRehash(encoded); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", encoded, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("legacy bcrypt under the default cost", func(t *testing.T) {
+		if !DefaultBcrypt().NeedsRehash(legacyBcryptHash) {
+			t.Error("NeedsRehash of a cost 4 hash = false")
+		}
+	})
+	t.Run("unreadable hash", func(t *testing.T) {
+		if !testScrypt().NeedsRehash("$scrypt$ln=5,r=8$c2FsdHNhbHQ$a2V5a2V5") {
+			t.Error("NeedsRehash of a hash without p = false")
+		}
+	})
+	t.Run("short key", func(t *testing.T) {
+		if !testArgon2id().NeedsRehash("$argon2id$v=19$m=64,t=2,p=2$c2FsdHNhbHQ$a2V5a2V5") {
+			t.Error("NeedsRehash of a 6-byte key = false")
+		}
+	})
+}
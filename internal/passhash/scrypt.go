@@ -0,0 +1,102 @@
+package passhash
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math/bits"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Scrypt hashes passwords with scrypt (RFC 7914)
+type Scrypt struct {
+	// N is the CPU and memory cost, a power of two
+	N int
+	R int
+	P int
+}
+
+// DefaultScrypt returns scrypt with N=32768, r=8 and p=1
+func DefaultScrypt() *Scrypt {
+	return &Scrypt{N: 1 << 15, R: 8, P: 1}
+}
+
+// scryptFromEnv reads the PASSWORD_SCRYPT_* settings
+func scryptFromEnv() (*Scrypt, error) {
+	s := DefaultScrypt()
+
+	var err error
+	if s.N, err = envInt("PASSWORD_SCRYPT_N", s.N); err != nil {
+		return nil, err
+	}
+	if s.R, err = envInt("PASSWORD_SCRYPT_R", s.R); err != nil {
+		return nil, err
+	}
+	if s.P, err = envInt("PASSWORD_SCRYPT_P", s.P); err != nil {
+		return nil, err
+	}
+
+	if s.N < 2 || s.N&(s.N-1) != 0 {
+		return nil, fmt.Errorf("PASSWORD_SCRYPT_N must be a power of two greater than 1")
+	}
+	if s.R < 1 || s.P < 1 || uint64(s.R)*uint64(s.P) >= 1<<30 {
+		return nil, fmt.Errorf("PASSWORD_SCRYPT_R and PASSWORD_SCRYPT_P must be positive with r*p < 2^30")
+	}
+
+	return s, nil
+}
+
+// Name returns "scrypt"
+func (s *Scrypt) Name() string {
+	return AlgorithmScrypt
+}
+
+// Hash hashes a password into a PHC string; N is stored as its base 2 logarithm ln
+func (s *Scrypt) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(password), salt, s.N, s.R, s.P, keyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return phcHash{
+		id:     AlgorithmScrypt,
+		params: map[string]int{"ln": bits.TrailingZeros(uint(s.N)), "r": s.R, "p": s.P},
+		salt:   salt,
+		key:    key,
+	}.String("ln", "r", "p"), nil
+}
+
+// Verify checks a password against a scrypt PHC string
+func (s *Scrypt) Verify(password, encoded string) error {
+	p, err := parsePHC(encoded, AlgorithmScrypt, "ln", "r", "p")
+	if err != nil {
+		return err
+	}
+	// scrypt.Key divides by r and p, so zero values must not reach it
+	if p.params["ln"] < 1 || p.params["ln"] > 30 || p.params["r"] < 1 || p.params["p"] < 1 {
+		return ErrUnknownFormat
+	}
+
+	key, err := scrypt.Key([]byte(password), p.salt, 1<<p.params["ln"], p.params["r"], p.params["p"], len(p.key))
+	if err != nil {
+		return ErrUnknownFormat
+	}
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether the hash uses a lower cost than configured
+func (s *Scrypt) NeedsRehash(encoded string) bool {
+	p, err := parsePHC(encoded, AlgorithmScrypt, "ln", "r", "p")
+	if err != nil {
+		return true
+	}
+	return 1<<p.params["ln"] < s.N || p.params["r"] < s.R || p.params["p"] < s.P || len(p.key) < keyLength
+}
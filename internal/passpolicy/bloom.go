@@ -0,0 +1,132 @@
+package passpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts every bloom filter file
+const bloomMagic = "PWBLOOM1"
+
+// bloomHeaderSize is the size of the magic, the number of bits and the number of hash functions
+const bloomHeaderSize = len(bloomMagic) + 8 + 4
+
+// maxBloomHashes bounds the hash functions per lookup; even a false positive rate of 1e-19 needs fewer
+const maxBloomHashes = 64
+
+// BloomFilter is a compact, in-memory set of SHA-1 hashes of leaked passwords.
+// It never misses a listed password but reports a small share of other passwords as leaked.
+type BloomFilter struct {
+	bits   []byte
+	m      uint64
+	hashes uint32
+}
+
+// NewBloomFilter creates an empty filter sized for n hashes at the given false positive rate
+func NewBloomFilter(n uint64, falsePositiveRate float64) (*BloomFilter, error) {
+	if n == 0 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.New("bloom filter needs at least one entry and a false positive rate between 0 and 1")
+	}
+
+	// Optimal size and number of hash functions for n entries
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	if hashes > maxBloomHashes {
+		return nil, errors.New("bloom filter false positive rate is too low")
+	}
+
+	return &BloomFilter{bits: make([]byte, (m+7)/8), m: m, hashes: hashes}, nil
+}
+
+// LoadBloomFilter reads a filter written by WriteTo
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, errors.New("not a breached password bloom filter")
+	}
+
+	f := &BloomFilter{
+		m:      binary.BigEndian.Uint64(header[len(bloomMagic):]),
+		hashes: binary.BigEndian.Uint32(header[len(bloomMagic)+8:]),
+	}
+	if f.m == 0 || f.hashes == 0 {
+		return nil, errors.New("bloom filter is empty")
+	}
+	if f.hashes > maxBloomHashes {
+		return nil, errors.New("bloom filter uses too many hash functions")
+	}
+
+	// The header must describe exactly the bits that follow it, so it cannot make us allocate more than the file holds
+	size := f.m/8 + (f.m%8+7)/8
+	if size != uint64(info.Size()-int64(bloomHeaderSize)) {
+		return nil, errors.New("bloom filter size does not match its header")
+	}
+
+	f.bits = make([]byte, size)
+	if _, err := io.ReadFull(reader, f.bits); err != nil {
+		return nil, errors.New("bloom filter is truncated")
+	}
+
+	return f, nil
+}
+
+// WriteTo writes the filter in the format read by LoadBloomFilter
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, bloomHeaderSize)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint64(header[len(bloomMagic):], f.m)
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+8:], f.hashes)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(f.bits)
+	return int64(n + m), err
+}
+
+// AddHash adds the SHA-1 hash of a leaked password
+func (f *BloomFilter) AddHash(sum [sha1.Size]byte) {
+	for _, bit := range f.positions(sum) {
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Contains reports whether the password is probably listed
+func (f *BloomFilter) Contains(password string) (bool, error) {
+	for _, bit := range f.positions(sha1.Sum([]byte(password))) {
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// positions derives the bits of a hash by double hashing; SHA-1 output is uniform enough to split
+func (f *BloomFilter) positions(sum [sha1.Size]byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	positions := make([]uint64, f.hashes)
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % f.m
+	}
+	return positions
+}
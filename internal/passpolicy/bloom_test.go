@@ -0,0 +1,135 @@
+package passpolicy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBloomFile writes raw filter bytes to a temporary file and returns its path
+func writeBloomFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// bloomHeader builds a filter header with the given number of bits and hash functions
+func bloomHeader(m uint64, hashes uint32) []byte {
+	header := make([]byte, bloomHeaderSize)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint64(header[len(bloomMagic):], m)
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+8:], hashes)
+	return header
+}
+
+func TestBloomFilterRoundTrip(t *testing.T) {
+	const listed = 2000
+	const falsePositiveRate = 0.01
+
+	filter, err := NewBloomFilter(listed, falsePositiveRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < listed; i++ {
+		filter.AddHash(sha1.Sum([]byte(fmt.Sprintf("leaked-%d", i))))
+	}
+
+	var buf bytes.Buffer
+	n, err := filter.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+
+	loaded, err := LoadBloomFilter(writeBloomFile(t, buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.m != filter.m || loaded.hashes != filter.hashes || !bytes.Equal(loaded.bits, filter.bits) {
+		t.Fatal("loaded filter differs from the written one")
+	}
+
+	// Every listed password must be found
+	for i := 0; i < listed; i++ {
+		password := fmt.Sprintf("leaked-%d", i)
+		if found, err := loaded.Contains(password); err != nil || !found {
+			t.Fatalf("Contains(%q) = %v, %v; want true", password, found, err)
+		}
+	}
+
+	// Other passwords are reported at about the configured rate
+	const others = 20000
+	falsePositives := 0
+	for i := 0; i < others; i++ {
+		if found, _ := loaded.Contains(fmt.Sprintf("other-%d", i)); found {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / others; rate > 3*falsePositiveRate {
+		t.Errorf("false positive rate = %.4f, want about %.4f", rate, falsePositiveRate)
+	}
+}
+
+func TestNewBloomFilterRejectsBadParameters(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		rate float64
+	}{
+		{n: 0, rate: 0.01},
+		{n: 10, rate: 0},
+		{n: 10, rate: 1},
+		{n: 10, rate: 1e-30},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewBloomFilter(tt.n, tt.rate); err == nil {
+			t.Errorf("NewBloomFilter(%d, %g) succeeded, want error", tt.n, tt.rate)
+		}
+	}
+}
+
+func TestLoadBloomFilterRejectsMalformedFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty file", data: nil},
+		{name: "short header", data: []byte(bloomMagic)},
+		{name: "wrong magic", data: append([]byte("PWBLOOM0"), bloomHeader(8, 1)[len(bloomMagic):]...)},
+		{name: "no bits", data: bloomHeader(0, 1)},
+		{name: "no hash functions", data: append(bloomHeader(8, 0), 0)},
+		{name: "too many hash functions", data: append(bloomHeader(8, maxBloomHashes+1), 0)},
+		{name: "truncated bits", data: append(bloomHeader(64, 3), make([]byte, 7)...)},
+		{name: "trailing data", data: append(bloomHeader(64, 3), make([]byte, 9)...)},
+		{name: "header larger than the file", data: append(bloomHeader(1<<62, 3), make([]byte, 8)...)},
+		{name: "bit count that overflows", data: append(bloomHeader(^uint64(0), 3), make([]byte, 8)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadBloomFilter(writeBloomFile(t, tt.data)); err == nil {
+				t.Error("LoadBloomFilter succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestLoadBloomFilterPartialByte(t *testing.T) {
+	// Ten bits take two bytes
+	filter, err := LoadBloomFilter(writeBloomFile(t, append(bloomHeader(10, 2), 0xff, 0x03)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := filter.Contains("anything"); !found {
+		t.Error("a filter with every bit set must contain every password")
+	}
+}
@@ -0,0 +1,73 @@
+package passpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is the number of hex digits of the SHA-1 hash that name a file of a PrefixDirectory
+const prefixLength = 5
+
+// OpenBreachedList opens a breached password corpus: a directory is read as a PrefixDirectory,
+// a file as a bloom filter written by WriteTo
+func OpenBreachedList(path string) (BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return NewPrefixDirectory(path), nil
+	}
+	return LoadBloomFilter(path)
+}
+
+// PrefixDirectory looks passwords up in a directory of range files as published by Have I Been Pwned:
+// the file 5BAA6.txt lists the remaining 35 hex digits of every leaked SHA-1 hash starting with 5BAA6,
+// one per line and optionally followed by ":<count>".
+// Only one small file is read per lookup, so the full corpus can stay on disk.
+type PrefixDirectory struct {
+	dir string
+}
+
+// NewPrefixDirectory creates a new PrefixDirectory instance
+func NewPrefixDirectory(dir string) *PrefixDirectory {
+	return &PrefixDirectory{dir: dir}
+}
+
+// Contains reports whether the SHA-1 hash of the password is listed
+func (d *PrefixDirectory) Contains(password string) (bool, error) {
+	digest := hashPassword(password)
+	prefix, suffix := digest[:prefixLength], digest[prefixLength:]
+
+	file, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if err != nil {
+		// Prefixes without leaked hashes may have no file
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), ":")
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// hashPassword returns the uppercase hex SHA-1 hash used by breached password corpora
+func hashPassword(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,69 @@
+package passpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefixDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8; the file name holds the first five digits
+	rangeFile := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" +
+		"1e4c9b93f3f0682250b6cf8331b7ee68fd8:9659365\n" +
+		"011053FD0102E94D6AE2F8B83D76FAF94F6:2\n"
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(rangeFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// "password1" hashes to E38AD..., whose file does not list it
+	if err := os.WriteFile(filepath.Join(dir, "E38AD.txt"), []byte(rangeFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		// Listed in lowercase with a count
+		{password: "password", want: true},
+		// Prefix file without the suffix
+		{password: "password1"},
+		// No file for the prefix
+		{password: "correct horse battery staple"},
+	}
+
+	list := NewPrefixDirectory(dir)
+	for _, tt := range tests {
+		got, err := list.Contains(tt.password)
+		if err != nil {
+			t.Fatalf("Contains(%q): %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestOpenBreachedList(t *testing.T) {
+	dir := t.TempDir()
+	list, err := OpenBreachedList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list.(*PrefixDirectory); !ok {
+		t.Errorf("directory opened as %T, want *PrefixDirectory", list)
+	}
+
+	path := writeBloomFile(t, append(bloomHeader(8, 1), 0))
+	list, err = OpenBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list.(*BloomFilter); !ok {
+		t.Errorf("file opened as %T, want *BloomFilter", list)
+	}
+
+	if _, err := OpenBreachedList(filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing corpus opened without error")
+	}
+}
@@ -0,0 +1,213 @@
+package passpolicy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation codes reported by Check
+const (
+	ViolationTooShort         = "too_short"
+	ViolationTooLong          = "too_long"
+	ViolationCharacterClasses = "character_classes"
+	ViolationContainsEmail    = "contains_email"
+	ViolationBreached         = "breached"
+)
+
+// Defaults of the settings read by PolicyFromEnv
+const (
+	defaultMinLength = 8
+	// bcryptMaxBytes is the length after which bcrypt ignores the rest of a password
+	bcryptMaxBytes  = 72
+	defaultMaxBytes = 256
+)
+
+// minEmailPartLength is the shortest part of an email address that passwords must not contain;
+// shorter parts match too many unrelated passwords
+const minEmailPartLength = 3
+
+// Violation is one rule a password breaks
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// BreachedList tells whether a password appears in a corpus of leaked passwords
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// Policy describes the passwords accepted for new accounts, password changes and resets
+type Policy struct {
+	// MinLength is the least number of characters
+	MinLength int
+	// MaxBytes is the largest size in bytes of the UTF-8 encoding
+	MaxBytes int
+	// MinCharacterClasses is how many of lowercase letters, uppercase letters, digits and
+	// other characters must appear
+	MinCharacterClasses int
+	// ForbidEmail rejects passwords containing the local part or a domain label of the user's email
+	ForbidEmail bool
+	// Breached rejects leaked passwords; nil skips the check
+	Breached BreachedList
+}
+
+// PolicyFromEnv reads the PASSWORD_* policy settings.
+// The default maximum length follows bcrypt's limit when bcrypt is the hash algorithm.
+func PolicyFromEnv(hashAlgorithm string) (*Policy, error) {
+	maxBytes := defaultMaxBytes
+	if hashAlgorithm == "bcrypt" {
+		maxBytes = bcryptMaxBytes
+	}
+
+	p := &Policy{ForbidEmail: true}
+	var err error
+	if p.MinLength, err = envInt("PASSWORD_MIN_LENGTH", defaultMinLength); err != nil {
+		return nil, err
+	}
+	if p.MaxBytes, err = envInt("PASSWORD_MAX_BYTES", maxBytes); err != nil {
+		return nil, err
+	}
+	if p.MinCharacterClasses, err = envInt("PASSWORD_MIN_CHARACTER_CLASSES", 0); err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("PASSWORD_FORBID_EMAIL"); value != "" {
+		if p.ForbidEmail, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("PASSWORD_FORBID_EMAIL must be a boolean: %w", err)
+		}
+	}
+
+	if p.MinLength < 1 {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be positive")
+	}
+	if p.MaxBytes < p.MinLength {
+		return nil, fmt.Errorf("PASSWORD_MAX_BYTES must not be less than PASSWORD_MIN_LENGTH")
+	}
+	if hashAlgorithm == "bcrypt" && p.MaxBytes > bcryptMaxBytes {
+		return nil, fmt.Errorf("PASSWORD_MAX_BYTES must not exceed %d with bcrypt", bcryptMaxBytes)
+	}
+	if p.MinCharacterClasses < 0 || p.MinCharacterClasses > 4 {
+		return nil, fmt.Errorf("PASSWORD_MIN_CHARACTER_CLASSES must be between 0 and 4")
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_CORPUS"); path != "" {
+		if p.Breached, err = OpenBreachedList(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Check returns every rule the password breaks; email is the address of the account, if known
+func (p *Policy) Check(password, email string) []Violation {
+	var violations []Violation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, Violation{
+			Code:    ViolationTooLong,
+			Message: fmt.Sprintf("password must be at most %d bytes", p.MaxBytes),
+		})
+	}
+	if characterClasses(password) < p.MinCharacterClasses {
+		violations = append(violations, Violation{
+			Code: ViolationCharacterClasses,
+			Message: fmt.Sprintf("password must contain at least %d of: lowercase letters, uppercase letters, digits, other characters",
+				p.MinCharacterClasses),
+		})
+	}
+	if p.ForbidEmail && containsEmailPart(password, email) {
+		violations = append(violations, Violation{
+			Code:    ViolationContainsEmail,
+			Message: "password must not contain parts of the email address",
+		})
+	}
+
+	// A lookup failure must not lock users out of setting a password
+	if p.Breached != nil && password != "" {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			log.Printf("Error checking breached passwords: %v", err)
+		} else if breached {
+			violations = append(violations, Violation{
+				Code:    ViolationBreached,
+				Message: "password appears in a list of leaked passwords",
+			})
+		}
+	}
+
+	return violations
+}
+
+// characterClasses counts which of lowercase letters, uppercase letters, digits and other characters appear
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// containsEmailPart reports whether the password contains the local part of the email, one of its
+// words or a domain label other than the top-level domain, ignoring case
+func containsEmailPart(password, email string) bool {
+	local, domain, found := strings.Cut(strings.ToLower(email), "@")
+	if !found {
+		return false
+	}
+
+	isSeparator := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	parts := append([]string{local}, strings.FieldsFunc(local, isSeparator)...)
+	if labels := strings.Split(domain, "."); len(labels) > 1 {
+		parts = append(parts, labels[:len(labels)-1]...)
+	}
+
+	password = strings.ToLower(password)
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minEmailPartLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// envInt reads a numeric setting, falling back to def when the variable is not set
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", name, err)
+	}
+	return number, nil
+}
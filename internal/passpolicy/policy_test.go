@@ -0,0 +1,126 @@
+package passpolicy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// stubList is a BreachedList with a fixed answer
+type stubList struct {
+	breached bool
+	err      error
+}
+
+func (l stubList) Contains(string) (bool, error) {
+	return l.breached, l.err
+}
+
+// violationCodes returns the codes of the violations in order
+func violationCodes(violations []Violation) []string {
+	codes := []string{}
+	for _, v := range violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+func TestPolicyCheck(t *testing.T) {
+	base := Policy{MinLength: 8, MaxBytes: 16}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		email    string
+		want     []string
+	}{
+		{name: "acceptable", policy: base, password: "horse-battery"},
+		{name: "too short", policy: base, password: "short", want: []string{ViolationTooShort}},
+		{name: "empty", policy: base, password: "", want: []string{ViolationTooShort}},
+		// Eight Cyrillic letters are 16 bytes but count as eight characters
+		{name: "length counts characters", policy: base, password: "пароль12"},
+		{name: "too long", policy: base, password: "horse-battery-staple", want: []string{ViolationTooLong}},
+		{name: "too long in bytes", policy: base, password: "парольпароль", want: []string{ViolationTooLong}},
+		{name: "no byte limit", policy: Policy{MinLength: 8}, password: "horse-battery-staple-correct"},
+		{name: "one class of three", policy: Policy{MinLength: 8, MinCharacterClasses: 3}, password: "horsebattery", want: []string{ViolationCharacterClasses}},
+		{name: "three classes", policy: Policy{MinLength: 8, MinCharacterClasses: 3}, password: "Horsebattery1"},
+		{name: "four classes", policy: Policy{MinLength: 8, MinCharacterClasses: 4}, password: "Horse-battery1"},
+		{name: "non-ASCII classes", policy: Policy{MinLength: 8, MinCharacterClasses: 3}, password: "Пароль123"},
+		{name: "contains local part", policy: Policy{MinLength: 8, ForbidEmail: true}, password: "my-alice.smith!", email: "Alice.Smith@example.com", want: []string{ViolationContainsEmail}},
+		{name: "contains local part word", policy: Policy{MinLength: 8, ForbidEmail: true}, password: "SMITH-family", email: "alice.smith@example.com", want: []string{ViolationContainsEmail}},
+		{name: "contains domain label", policy: Policy{MinLength: 8, ForbidEmail: true}, password: "example-pass", email: "alice@example.com", want: []string{ViolationContainsEmail}},
+		{name: "top-level domain allowed", policy: Policy{MinLength: 8, ForbidEmail: true}, password: "com-puter-1", email: "alice@example.com"},
+		{name: "short parts allowed", policy: Policy{MinLength: 8, ForbidEmail: true}, password: "al-bo-house", email: "al.bo@io.com"},
+		{name: "email check off", policy: Policy{MinLength: 8}, password: "alice-pass", email: "alice@example.com"},
+		{name: "breached", policy: Policy{MinLength: 8, Breached: stubList{breached: true}}, password: "password", want: []string{ViolationBreached}},
+		{name: "breached lookup fails", policy: Policy{MinLength: 8, Breached: stubList{err: errors.New("disk error")}}, password: "password"},
+		{
+			name:     "every violation",
+			policy:   Policy{MinLength: 30, MaxBytes: 10, MinCharacterClasses: 2, ForbidEmail: true, Breached: stubList{breached: true}},
+			password: "alicealicealice",
+			email:    "alice@example.com",
+			want:     []string{ViolationTooShort, ViolationTooLong, ViolationCharacterClasses, ViolationContainsEmail, ViolationBreached},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == nil {
+				want = []string{}
+			}
+			if got := violationCodes(tt.policy.Check(tt.password, tt.email)); !reflect.DeepEqual(got, want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.password, got, want)
+			}
+		})
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		env       map[string]string
+		want      Policy
+		wantErr   bool
+	}{
+		{name: "defaults", algorithm: "argon2id", want: Policy{MinLength: defaultMinLength, MaxBytes: defaultMaxBytes, ForbidEmail: true}},
+		{name: "bcrypt default", algorithm: "bcrypt", want: Policy{MinLength: defaultMinLength, MaxBytes: bcryptMaxBytes, ForbidEmail: true}},
+		{
+			name:      "overrides",
+			algorithm: "argon2id",
+			env:       map[string]string{"PASSWORD_MIN_LENGTH": "12", "PASSWORD_MAX_BYTES": "64", "PASSWORD_MIN_CHARACTER_CLASSES": "3", "PASSWORD_FORBID_EMAIL": "false"},
+			want:      Policy{MinLength: 12, MaxBytes: 64, MinCharacterClasses: 3},
+		},
+		{name: "bcrypt over 72 bytes", algorithm: "bcrypt", env: map[string]string{"PASSWORD_MAX_BYTES": "100"}, wantErr: true},
+		{name: "zero minimum", algorithm: "argon2id", env: map[string]string{"PASSWORD_MIN_LENGTH": "0"}, wantErr: true},
+		{name: "maximum below minimum", algorithm: "argon2id", env: map[string]string{"PASSWORD_MIN_LENGTH": "20", "PASSWORD_MAX_BYTES": "10"}, wantErr: true},
+		{name: "five classes", algorithm: "argon2id", env: map[string]string{"PASSWORD_MIN_CHARACTER_CLASSES": "5"}, wantErr: true},
+		{name: "not a number", algorithm: "argon2id", env: map[string]string{"PASSWORD_MIN_LENGTH": "eight"}, wantErr: true},
+		{name: "not a boolean", algorithm: "argon2id", env: map[string]string{"PASSWORD_FORBID_EMAIL": "sometimes"}, wantErr: true},
+		{name: "missing corpus", algorithm: "argon2id", env: map[string]string{"PASSWORD_BREACHED_CORPUS": "/nonexistent/corpus"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"PASSWORD_MIN_LENGTH", "PASSWORD_MAX_BYTES", "PASSWORD_MIN_CHARACTER_CLASSES", "PASSWORD_FORBID_EMAIL", "PASSWORD_BREACHED_CORPUS"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			got, err := PolicyFromEnv(tt.algorithm)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("PolicyFromEnv = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("PolicyFromEnv = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
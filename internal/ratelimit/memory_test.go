@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// ageBucket moves the last update of a bucket into the past, as if d had passed since
+func ageBucket(t *testing.T, s *MemoryStore, key string, d time.Duration) {
+	t.Helper()
+	b, ok := s.buckets[key]
+	if !ok {
+		t.Fatalf("no bucket for %q", key)
+	}
+	b.updated = b.updated.Add(-d)
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 5, Period: time.Minute}
+	s := NewMemoryStore()
+
+	for i := 1; i <= policy.Limit; i++ {
+		result, err := s.Take("key", policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d rejected within the burst", i)
+		}
+		if result.Remaining != policy.Limit-i {
+			t.Errorf("request %d: remaining = %d, want %d", i, result.Remaining, policy.Limit-i)
+		}
+	}
+
+	result, err := s.Take("key", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("request over the burst allowed")
+	}
+	if result.Remaining != 0 {
+		t.Errorf("remaining = %d, want 0", result.Remaining)
+	}
+	// One token comes back every Period/Limit
+	if want := policy.Period / time.Duration(policy.Limit); result.RetryAfter <= 0 || result.RetryAfter > want {
+		t.Errorf("retry after = %s, want at most %s", result.RetryAfter, want)
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 6, Period: time.Minute}
+	interval := policy.Period / time.Duration(policy.Limit)
+
+	tests := []struct {
+		name        string
+		elapsed     time.Duration
+		wantAllowed int
+	}{
+		{name: "no time passed", elapsed: 0, wantAllowed: 0},
+		{name: "less than one interval", elapsed: interval / 2, wantAllowed: 0},
+		{name: "one interval", elapsed: interval, wantAllowed: 1},
+		{name: "three intervals", elapsed: 3 * interval, wantAllowed: 3},
+		{name: "capped at the limit", elapsed: 10 * policy.Period, wantAllowed: policy.Limit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			for i := 0; i < policy.Limit; i++ {
+				s.Take("key", policy)
+			}
+			ageBucket(t, s, "key", tt.elapsed)
+
+			allowed := 0
+			for i := 0; i <= policy.Limit; i++ {
+				result, err := s.Take("key", policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Allowed {
+					break
+				}
+				allowed++
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d requests after %s, want %d", allowed, tt.elapsed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeyIsolation(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 2, Period: time.Minute}
+	s := NewMemoryStore()
+
+	for i := 0; i < policy.Limit; i++ {
+		s.Take("a", policy)
+	}
+	if result, _ := s.Take("a", policy); result.Allowed {
+		t.Fatal("exhausted key still allowed")
+	}
+
+	result, err := s.Take("b", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != policy.Limit-1 {
+		t.Errorf("other key = %+v, want allowed with %d remaining", result, policy.Limit-1)
+	}
+}
+
+func TestLimiterSeparatesPolicies(t *testing.T) {
+	first := Policy{Name: "first", Limit: 1, Period: time.Minute}
+	second := Policy{Name: "second", Limit: 1, Period: time.Minute}
+	limiter := NewLimiter(NewMemoryStore())
+
+	if !limiter.Take(first, "key").Allowed {
+		t.Fatal("first request rejected")
+	}
+	if limiter.Take(first, "key").Allowed {
+		t.Fatal("second request under the same policy allowed")
+	}
+	if !limiter.Take(second, "key").Allowed {
+		t.Error("the same key under another policy shares the bucket")
+	}
+	if !limiter.Take(Policy{Name: "off"}, "key").Allowed {
+		t.Error("disabled policy rejected a request")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 1, Period: time.Minute}
+	s := NewMemoryStore()
+
+	s.Take("idle", policy)
+	s.Take("busy", policy)
+	ageBucket(t, s, "idle", policy.Period)
+	s.lastSweep = s.lastSweep.Add(-sweepInterval)
+
+	s.Take("other", policy)
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit requests per Period, refilled continuously like a token bucket.
+// A full bucket lets a client burst up to Limit requests at once.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled reports whether the policy limits anything; policies turned off in the config have no limit
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+// rate returns how many tokens are added to the bucket per second
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// Policies shared by the HTTP and gRPC APIs, so that both count against the same buckets
+var (
+	// RegisterPolicy limits account creation from one address
+	RegisterPolicy = Policy{Name: "register", Limit: 20, Period: time.Hour}
+	// LoginIPPolicy limits password logins from one address
+	LoginIPPolicy = Policy{Name: "login_ip", Limit: 60, Period: time.Minute}
+	// LoginEmailPolicy limits password logins for one account
+	LoginEmailPolicy = Policy{Name: "login_email", Limit: 20, Period: time.Minute}
+	// RefreshPolicy limits refresh token exchanges from one address
+	RefreshPolicy = Policy{Name: "refresh", Limit: 120, Period: time.Minute}
+	// ClientPolicy limits requests of one authenticated OAuth client or token subject
+	ClientPolicy = Policy{Name: "client", Limit: 600, Period: time.Minute}
+)
+
+// periodUnits maps the units accepted in policy strings to durations
+var periodUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParsePolicy parses a policy string such as "30/m" (30 requests per minute) or "off"
+func ParsePolicy(name, value string) (Policy, error) {
+	if value == "off" {
+		return Policy{Name: name}, nil
+	}
+
+	count, unit, found := strings.Cut(value, "/")
+	if !found {
+		return Policy{}, fmt.Errorf("rate limit %q must look like 30/m", value)
+	}
+
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q must start with a positive count", value)
+	}
+
+	period, ok := periodUnits[unit]
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %q must end with s, m, h or d", value)
+	}
+
+	return Policy{Name: name, Limit: limit, Period: period}, nil
+}
+
+// PolicyFromEnv returns the policy with its RATE_LIMIT_<NAME> override applied, if any
+func PolicyFromEnv(policy Policy) Policy {
+	variable := "RATE_LIMIT_" + strings.ToUpper(policy.Name)
+	value := os.Getenv(variable)
+	if value == "" {
+		return policy
+	}
+
+	parsed, err := ParsePolicy(policy.Name, value)
+	if err != nil {
+		log.Printf("Invalid %s: %v, using %d/%s", variable, err, policy.Limit, policy.Period)
+		return policy
+	}
+	return parsed
+}
+
+// Result describes the state of a bucket after a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long a rejected client must wait for the next token
+	RetryAfter time.Duration
+}
+
+// NewResult builds the result of a request from the tokens left in the bucket
+func NewResult(policy Policy, tokens float64, allowed bool) Result {
+	rate := policy.rate()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(policy.Limit) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result
+}
+
+// Strictest returns the result closest to rejecting the request, a rejection if there is one
+func Strictest(results []Result) (Result, bool) {
+	if len(results) == 0 {
+		return Result{}, false
+	}
+
+	strictest := results[0]
+	for _, result := range results[1:] {
+		switch {
+		case !result.Allowed && (strictest.Allowed || result.RetryAfter > strictest.RetryAfter):
+			strictest = result
+		case result.Allowed && strictest.Allowed && result.Remaining < strictest.Remaining:
+			strictest = result
+		}
+	}
+	return strictest, true
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps token buckets.
+// Take removes one token from the bucket of the key if there is one.
+type Store interface {
+	Take(key string, policy Policy) (Result, error)
+}
+
+// Limiter applies policies to keys using a Store
+type Limiter struct {
+	store Store
+}
+
+// NewLimiter creates a new Limiter instance
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Take counts a request against the bucket of the key under the policy.
+// Store failures let the request through, so that an outage of the store does not take the API down.
+func (l *Limiter) Take(policy Policy, key string) Result {
+	if !policy.Enabled() {
+		return Result{Allowed: true}
+	}
+
+	result, err := l.store.Take(policy.Name+":"+key, policy)
+	if err != nil {
+		log.Printf("Error applying rate limit %s: %v", policy.Name, err)
+		return Result{Allowed: true, Limit: policy.Limit, Remaining: policy.Limit}
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Policy
+		wantErr bool
+	}{
+		{value: "30/m", want: Policy{Name: "test", Limit: 30, Period: time.Minute}},
+		{value: "5/h", want: Policy{Name: "test", Limit: 5, Period: time.Hour}},
+		{value: "1/s", want: Policy{Name: "test", Limit: 1, Period: time.Second}},
+		{value: "100/d", want: Policy{Name: "test", Limit: 100, Period: 24 * time.Hour}},
+		{value: "off", want: Policy{Name: "test"}},
+		{value: "30", wantErr: true},
+		{value: "0/m", wantErr: true},
+		{value: "-1/m", wantErr: true},
+		{value: "x/m", wantErr: true},
+		{value: "30/w", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePolicy("test", tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePolicy(%q) = %+v, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %+v, %v; want %+v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestStrictest(t *testing.T) {
+	allowedMany := Result{Allowed: true, Remaining: 10}
+	allowedFew := Result{Allowed: true, Remaining: 1}
+	rejectedSoon := Result{Remaining: 0, RetryAfter: time.Second}
+	rejectedLater := Result{Remaining: 0, RetryAfter: time.Minute}
+
+	tests := []struct {
+		name    string
+		results []Result
+		want    Result
+		wantOK  bool
+	}{
+		{name: "none"},
+		{name: "fewest remaining", results: []Result{allowedMany, allowedFew}, want: allowedFew, wantOK: true},
+		{name: "rejection wins", results: []Result{allowedFew, rejectedSoon, allowedMany}, want: rejectedSoon, wantOK: true},
+		{name: "longest wait", results: []Result{rejectedSoon, rejectedLater}, want: rejectedLater, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Strictest(tt.results)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Strictest = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
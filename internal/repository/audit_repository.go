@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepository provides access to the audit log
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+// NewAuditRepository creates a new AuditRepository instance
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record appends an event to the audit log
+func (r *AuditRepository) Record(event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (event, outcome, actor_id, subject_id, subject_email, client_id, ip, user_agent, reason)
+		VALUES (:event, :outcome, :actor_id, :subject_id, :subject_email, :client_id, :ip, :user_agent, :reason)
+	`
+
+	_, err := r.db.NamedExec(query, event)
+	if err != nil {
+		log.Printf("Error recording audit event: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListEvents returns the events matching the filter, newest first
+func (r *AuditRepository) ListEvents(filter models.AuditEventFilter) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Event != "" {
+		where("event = $%d", filter.Event)
+	}
+	if filter.Outcome != "" {
+		where("outcome = $%d", filter.Outcome)
+	}
+	if filter.ActorID != "" {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.SubjectID != nil {
+		where("subject_id = $%d", *filter.SubjectID)
+	}
+	if filter.IP != "" {
+		where("ip = $%d", filter.IP)
+	}
+	if filter.From != nil {
+		where("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < $%d", *filter.To)
+	}
+	if filter.Before > 0 {
+		where("id < $%d", filter.Before)
+	}
+
+	query := `
+		SELECT id, event, outcome, actor_id, subject_id, subject_email, client_id, ip, user_agent, reason, created_at
+		FROM audit_events
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	events := []models.AuditEvent{}
+	err := r.db.Select(&events, query, args...)
+	if err != nil {
+		log.Printf("Error listing audit events: %v", err)
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"log"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ClientRepository provides access to the OAuth client storage
+type ClientRepository struct {
+	db *sqlx.DB
+}
+
+// NewClientRepository creates a new ClientRepository instance
+func NewClientRepository(db *sqlx.DB) *ClientRepository {
+	return &ClientRepository{db: db}
+}
+
+// CreateClient stores a new OAuth client
+func (r *ClientRepository) CreateClient(client *models.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, public, allowed_scopes, audience)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRow(query, client.ID, client.Name, client.SecretHash, client.RedirectURIs, client.Public,
+		client.AllowedScopes, client.Audience).Scan(&client.CreatedAt)
+	if err != nil {
+		log.Printf("Error creating OAuth client: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetClient retrieves an OAuth client by ID
+func (r *ClientRepository) GetClient(id string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	query := `SELECT id, name, secret_hash, redirect_uris, public, allowed_scopes, audience, created_at FROM oauth_clients WHERE id = $1`
+
+	err := r.db.Get(&client, query, id)
+	if err != nil {
+		log.Printf("Error getting OAuth client: %v", err)
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// ListClients returns every registered OAuth client
+func (r *ClientRepository) ListClients() ([]models.OAuthClient, error) {
+	clients := []models.OAuthClient{}
+	query := `SELECT id, name, secret_hash, redirect_uris, public, allowed_scopes, audience, created_at FROM oauth_clients ORDER BY created_at`
+
+	err := r.db.Select(&clients, query)
+	if err != nil {
+		log.Printf("Error listing OAuth clients: %v", err)
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// DeleteClient removes an OAuth client and reports whether it existed
+func (r *ClientRepository) DeleteClient(id string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Error deleting OAuth client: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
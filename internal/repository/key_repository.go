@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"log"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// KeyRepository provides access to the signing key storage
+type KeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewKeyRepository creates a new KeyRepository instance
+func NewKeyRepository(db *sqlx.DB) *KeyRepository {
+	return &KeyRepository{db: db}
+}
+
+// ListSigningKeys returns every stored signing key
+func (r *KeyRepository) ListSigningKeys() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	query := `SELECT id, algorithm, key_material, status, created_at, retired_at FROM signing_keys ORDER BY created_at`
+
+	err := r.db.Select(&keys, query)
+	if err != nil {
+		log.Printf("Error listing signing keys: %v", err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// CreateSigningKey stores a new signing key
+func (r *KeyRepository) CreateSigningKey(key *models.SigningKey) error {
+	query := `
+		INSERT INTO signing_keys (id, algorithm, key_material, status)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(query, key.ID, key.Algorithm, key.KeyMaterial, key.Status)
+	if err != nil {
+		log.Printf("Error creating signing key: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// PromoteSigningKey makes a key active and retires the previously active key
+func (r *KeyRepository) PromoteSigningKey(id string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE signing_keys SET status = 'retired', retired_at = NOW() WHERE status = 'active' AND id <> $1`, id)
+	if err != nil {
+		log.Printf("Error retiring active signing key: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE signing_keys SET status = 'active', retired_at = NULL WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Error promoting signing key: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing key promotion: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RetireSigningKey marks a key as retired
+func (r *KeyRepository) RetireSigningKey(id string) error {
+	query := `UPDATE signing_keys SET status = 'retired', retired_at = NOW() WHERE id = $1 AND status <> 'retired'`
+
+	_, err := r.db.Exec(query, id)
+	if err != nil {
+		log.Printf("Error retiring signing key: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSigningKey removes a key from the store
+func (r *KeyRepository) DeleteSigningKey(id string) error {
+	_, err := r.db.Exec(`DELETE FROM signing_keys WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Error deleting signing key: %v", err)
+		return err
+	}
+
+	return nil
+}
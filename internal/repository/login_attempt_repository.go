@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Scopes of the failed login counters
+const (
+	AttemptScopeAccount = "account"
+	AttemptScopeIP      = "ip"
+	// AttemptScopeMFAToken counts wrong codes entered for one MFA challenge, keyed by the token ID
+	AttemptScopeMFAToken = "mfa_token"
+)
+
+// LoginAttemptRepository provides access to the failed login counters.
+// Counters live in Postgres so that lockouts survive restarts and apply to every replica.
+type LoginAttemptRepository struct {
+	db *sqlx.DB
+}
+
+// NewLoginAttemptRepository creates a new LoginAttemptRepository instance
+func NewLoginAttemptRepository(db *sqlx.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// LockedFor returns how long logins for the account or the source address stay blocked.
+// Zero means neither is locked; an empty ip is not checked.
+func (r *LoginAttemptRepository) LockedFor(account, ip string) (time.Duration, error) {
+	var seconds float64
+	query := `
+		SELECT COALESCE(MAX(EXTRACT(EPOCH FROM locked_until - NOW())), 0)
+		FROM login_attempts
+		WHERE locked_until > NOW()
+		  AND ((scope = $1 AND key = $2) OR (scope = $3 AND key = $4 AND $4 <> ''))
+	`
+
+	err := r.db.QueryRow(query, AttemptScopeAccount, account, AttemptScopeIP, ip).Scan(&seconds)
+	if err != nil {
+		log.Printf("Error checking login lockout: %v", err)
+		return 0, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// IsLocked reports whether the key is blocked right now
+func (r *LoginAttemptRepository) IsLocked(scope, key string) (bool, error) {
+	var locked bool
+	query := `SELECT EXISTS (SELECT 1 FROM login_attempts WHERE scope = $1 AND key = $2 AND locked_until > NOW())`
+
+	err := r.db.QueryRow(query, scope, key).Scan(&locked)
+	if err != nil {
+		log.Printf("Error checking lock: %v", err)
+		return false, err
+	}
+
+	return locked, nil
+}
+
+// RecordFailure counts a failed login and returns the number of failures in a row.
+// The count starts over when the previous failure is older than the window.
+func (r *LoginAttemptRepository) RecordFailure(scope, key string, window time.Duration) (int, error) {
+	var failures int
+	query := `
+		INSERT INTO login_attempts (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, key) DO UPDATE
+		SET failures = CASE
+		        WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $3) THEN 1
+		        ELSE login_attempts.failures + 1
+		    END,
+		    last_failure_at = NOW()
+		RETURNING failures
+	`
+
+	err := r.db.QueryRow(query, scope, key, window.Seconds()).Scan(&failures)
+	if err != nil {
+		log.Printf("Error recording failed login: %v", err)
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// Lock blocks logins for the key for the given duration
+func (r *LoginAttemptRepository) Lock(scope, key string, duration time.Duration) error {
+	query := `UPDATE login_attempts SET locked_until = NOW() + make_interval(secs => $3) WHERE scope = $1 AND key = $2`
+
+	_, err := r.db.Exec(query, scope, key, duration.Seconds())
+	if err != nil {
+		log.Printf("Error locking logins: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Reset clears the failure count and any lock of the key
+func (r *LoginAttemptRepository) Reset(scope, key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		log.Printf("Error resetting failed logins: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// PurgeStale removes counters whose last failure is older than the window and that are not locked
+func (r *LoginAttemptRepository) PurgeStale(window time.Duration) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < NOW() - make_interval(secs => $1)
+		  AND (locked_until IS NULL OR locked_until < NOW())
+	`
+
+	_, err := r.db.Exec(query, window.Seconds())
+	if err != nil {
+		log.Printf("Error purging failed login counters: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// MFARepository provides access to the second factor storage
+type MFARepository struct {
+	db *sqlx.DB
+}
+
+// NewMFARepository creates a new MFARepository instance
+func NewMFARepository(db *sqlx.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// SaveTOTPSecret stores a pending TOTP secret, replacing an earlier unconfirmed one.
+// It reports false if TOTP is already enabled for the user.
+func (r *MFARepository) SaveTOTPSecret(userID uuid.UUID, secret string) (bool, error) {
+	query := `
+		INSERT INTO mfa_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_counter = 0, created_at = NOW()
+		WHERE mfa_totp.enabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, secret)
+	if err != nil {
+		log.Printf("Error saving TOTP secret: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// GetTOTP retrieves a user's TOTP enrollment
+func (r *MFARepository) GetTOTP(userID uuid.UUID) (*models.TOTPCredential, error) {
+	var credential models.TOTPCredential
+	query := `SELECT user_id, secret, last_counter, enabled_at, created_at FROM mfa_totp WHERE user_id = $1`
+
+	err := r.db.Get(&credential, query, userID)
+	if err != nil {
+		log.Printf("Error getting TOTP enrollment: %v", err)
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// EnableTOTP activates a confirmed TOTP enrollment
+func (r *MFARepository) EnableTOTP(userID uuid.UUID) error {
+	_, err := r.db.Exec(`UPDATE mfa_totp SET enabled_at = NOW() WHERE user_id = $1 AND enabled_at IS NULL`, userID)
+	if err != nil {
+		log.Printf("Error enabling TOTP: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes a user's TOTP enrollment
+func (r *MFARepository) DeleteTOTP(userID uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM mfa_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Printf("Error deleting TOTP enrollment: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPCounter records the period of an accepted code.
+// It reports false if that period or a later one was already used, which means the code was replayed.
+func (r *MFARepository) UseTOTPCounter(userID uuid.UUID, counter int64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE mfa_totp SET last_counter = $2 WHERE user_id = $1 AND last_counter < $2`, userID, counter)
+	if err != nil {
+		log.Printf("Error recording TOTP counter: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// MFAMethods returns the second factors enabled for a user
+func (r *MFARepository) MFAMethods(userID uuid.UUID) ([]string, error) {
+	methods := []string{}
+	query := `
+		SELECT 'totp' FROM mfa_totp WHERE user_id = $1 AND enabled_at IS NOT NULL
+		UNION ALL
+		SELECT 'webauthn' WHERE EXISTS(SELECT 1 FROM webauthn_credentials WHERE user_id = $1)
+		UNION ALL
+		SELECT 'recovery_code' WHERE EXISTS(SELECT 1 FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL)
+	`
+
+	err := r.db.Select(&methods, query, userID)
+	if err != nil {
+		log.Printf("Error listing MFA methods: %v", err)
+		return nil, err
+	}
+
+	return methods, nil
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and stores a new set of hashes
+func (r *MFARepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Printf("Error deleting recovery codes: %v", err)
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			log.Printf("Error saving recovery code: %v", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing recovery codes: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListUnusedRecoveryCodes returns the recovery codes the user has not used yet
+func (r *MFARepository) ListUnusedRecoveryCodes(userID uuid.UUID) ([]models.RecoveryCode, error) {
+	codes := []models.RecoveryCode{}
+	query := `SELECT id, user_id, code_hash, used_at, created_at FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	err := r.db.Select(&codes, query, userID)
+	if err != nil {
+		log.Printf("Error listing recovery codes: %v", err)
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode marks a recovery code as used and reports false if it had already been used
+func (r *MFARepository) UseRecoveryCode(id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec(`UPDATE mfa_recovery_codes SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, id)
+	if err != nil {
+		log.Printf("Error using recovery code: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// DeleteRecoveryCodes removes all of a user's recovery codes
+func (r *MFARepository) DeleteRecoveryCodes(userID uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Printf("Error deleting recovery codes: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// webAuthnCredentialColumns lists the columns selected for a WebAuthnCredential
+const webAuthnCredentialColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, name, transports, created_at, last_used_at`
+
+// CreateWebAuthnCredential stores a newly registered credential.
+// It reports false if the credential ID is already registered.
+func (r *MFARepository) CreateWebAuthnCredential(credential *models.WebAuthnCredential) (bool, error) {
+	query := `
+		INSERT INTO webauthn_credentials (user_id, credential_id, public_key, sign_count, aaguid, name, transports)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (credential_id) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	rows, err := r.db.Query(query, credential.UserID, credential.CredentialID, credential.PublicKey,
+		credential.SignCount, credential.AAGUID, credential.Name, credential.Transports)
+	if err != nil {
+		log.Printf("Error creating WebAuthn credential: %v", err)
+		return false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	if err := rows.Scan(&credential.ID, &credential.CreatedAt); err != nil {
+		log.Printf("Error reading created WebAuthn credential: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetWebAuthnCredential retrieves a credential by the ID the authenticator assigned to it
+func (r *MFARepository) GetWebAuthnCredential(credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	query := `SELECT ` + webAuthnCredentialColumns + ` FROM webauthn_credentials WHERE credential_id = $1`
+
+	err := r.db.Get(&credential, query, credentialID)
+	if err != nil {
+		log.Printf("Error getting WebAuthn credential: %v", err)
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// ListWebAuthnCredentials returns the credentials registered by a user, oldest first
+func (r *MFARepository) ListWebAuthnCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	credentials := []models.WebAuthnCredential{}
+	query := `SELECT ` + webAuthnCredentialColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`
+
+	err := r.db.Select(&credentials, query, userID)
+	if err != nil {
+		log.Printf("Error listing WebAuthn credentials: %v", err)
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// DeleteWebAuthnCredential removes a user's credential and reports whether it existed
+func (r *MFARepository) DeleteWebAuthnCredential(userID, id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		log.Printf("Error deleting WebAuthn credential: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// UseWebAuthnCredential records the signature counter of an accepted assertion.
+// It reports false if the counter did not increase, which suggests a cloned authenticator.
+// Authenticators that do not implement a counter always report zero and are accepted.
+func (r *MFARepository) UseWebAuthnCredential(id uuid.UUID, signCount int64) (bool, error) {
+	query := `
+		UPDATE webauthn_credentials SET sign_count = $2, last_used_at = NOW()
+		WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))
+	`
+
+	result, err := r.db.Exec(query, id, signCount)
+	if err != nil {
+		log.Printf("Error recording WebAuthn signature counter: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// CreateWebAuthnSession stores the challenge of a new ceremony
+func (r *MFARepository) CreateWebAuthnSession(userID *uuid.UUID, ceremony, challenge string, expiresAt time.Time) (uuid.UUID, error) {
+	var id uuid.UUID
+	query := `INSERT INTO webauthn_sessions (user_id, ceremony, challenge, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	err := r.db.QueryRow(query, userID, ceremony, challenge, expiresAt).Scan(&id)
+	if err != nil {
+		log.Printf("Error creating WebAuthn session: %v", err)
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// ConsumeWebAuthnSession removes and returns a ceremony so that its challenge can only be answered once
+func (r *MFARepository) ConsumeWebAuthnSession(id uuid.UUID) (*models.WebAuthnSession, error) {
+	var session models.WebAuthnSession
+	query := `DELETE FROM webauthn_sessions WHERE id = $1 RETURNING id, user_id, ceremony, challenge, expires_at, created_at`
+
+	err := r.db.Get(&session, query, id)
+	if err != nil {
+		log.Printf("Error consuming WebAuthn session: %v", err)
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// PurgeExpiredWebAuthnSessions removes ceremonies that were never completed
+func (r *MFARepository) PurgeExpiredWebAuthnSessions() error {
+	_, err := r.db.Exec(`DELETE FROM webauthn_sessions WHERE expires_at < NOW()`)
+	if err != nil {
+		log.Printf("Error purging expired WebAuthn sessions: %v", err)
+		return err
+	}
+
+	return nil
+}
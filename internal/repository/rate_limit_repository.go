@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"log"
+
+	"github.com/diplom/auth-service/internal/ratelimit"
+	"github.com/jmoiron/sqlx"
+)
+
+// RateLimitRepository keeps rate limit token buckets in Postgres so that limits hold across replicas.
+// It implements ratelimit.Store.
+type RateLimitRepository struct {
+	db *sqlx.DB
+}
+
+// NewRateLimitRepository creates a new RateLimitRepository instance
+func NewRateLimitRepository(db *sqlx.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// Take removes one token from the bucket of the key if there is one.
+// The refill and the removal happen in a single statement, so concurrent requests cannot overspend.
+func (r *RateLimitRepository) Take(key string, policy ratelimit.Policy) (ratelimit.Result, error) {
+	var tokens float64
+	var allowed bool
+	query := `
+		INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+		VALUES ($1, $2::float8 - 1, TRUE, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = LEAST($2::float8, rate_limit_buckets.tokens + $3::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8)
+		           - CASE WHEN LEAST($2::float8, rate_limit_buckets.tokens + $3::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8) >= 1
+		                  THEN 1 ELSE 0 END,
+		    allowed = LEAST($2::float8, rate_limit_buckets.tokens + $3::float8 * EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at)::float8) >= 1,
+		    updated_at = NOW()
+		RETURNING tokens, allowed
+	`
+
+	rate := float64(policy.Limit) / policy.Period.Seconds()
+	err := r.db.QueryRow(query, key, policy.Limit, rate).Scan(&tokens, &allowed)
+	if err != nil {
+		log.Printf("Error taking rate limit token: %v", err)
+		return ratelimit.Result{}, err
+	}
+
+	return ratelimit.NewResult(policy, tokens, allowed), nil
+}
+
+// PurgeIdle removes buckets that have not been used for a day; they would be full again anyway
+func (r *RateLimitRepository) PurgeIdle() error {
+	_, err := r.db.Exec(`DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - INTERVAL '1 day'`)
+	if err != nil {
+		log.Printf("Error purging idle rate limit buckets: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// RoleRepository provides access to roles, permissions and the roles of users
+type RoleRepository struct {
+	db *sqlx.DB
+}
+
+// NewRoleRepository creates a new RoleRepository instance
+func NewRoleRepository(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// roleSelect loads roles together with their sorted permissions
+const roleSelect = `
+	SELECT r.name, r.description, r.created_at,
+		COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}') AS permissions
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role = r.name
+`
+
+// ListRoles returns every role, sorted by name
+func (r *RoleRepository) ListRoles() ([]models.Role, error) {
+	roles := []models.Role{}
+	err := r.db.Select(&roles, roleSelect+` GROUP BY r.name ORDER BY r.name`)
+	if err != nil {
+		log.Printf("Error listing roles: %v", err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GetRole retrieves a role by name
+func (r *RoleRepository) GetRole(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Get(&role, roleSelect+` WHERE r.name = $1 GROUP BY r.name`, name)
+	if err != nil {
+		log.Printf("Error getting role: %v", err)
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// CreateRole stores a new role with its permissions.
+// It reports false when a role with that name already exists.
+func (r *RoleRepository) CreateRole(role *models.Role) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO roles (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING created_at
+	`, role.Name, role.Description).Scan(&role.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		log.Printf("Error creating role: %v", err)
+		return false, err
+	}
+
+	if err := setRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing role: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateRole replaces the description and the permissions of a role.
+// It reports false when the role does not exist.
+func (r *RoleRepository) UpdateRole(role *models.Role) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE roles SET description = $2 WHERE name = $1`, role.Name, role.Description)
+	if err != nil {
+		log.Printf("Error updating role: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = $1`, role.Name); err != nil {
+		log.Printf("Error clearing role permissions: %v", err)
+		return false, err
+	}
+	if err := setRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing role: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setRolePermissions grants permissions to a role within a transaction
+func setRolePermissions(tx *sqlx.Tx, role string, permissions []string) error {
+	_, err := tx.Exec(`
+		INSERT INTO role_permissions (role, permission)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING
+	`, role, pq.Array(permissions))
+	if err != nil {
+		log.Printf("Error granting role permissions: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteRole removes a role from every user that holds it and reports whether it existed.
+// Users whose primary role it was get another of their roles as primary.
+func (r *RoleRepository) DeleteRole(name string) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM roles WHERE name = $1`, name)
+	if err != nil {
+		log.Printf("Error deleting role: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET role = COALESCE((SELECT MIN(role) FROM user_roles WHERE user_id = users.id), '')
+		WHERE role = $1
+	`, name)
+	if err != nil {
+		log.Printf("Error reassigning primary roles: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing role deletion: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListPermissions returns every permission, sorted by name
+func (r *RoleRepository) ListPermissions() ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	err := r.db.Select(&permissions, `SELECT name, description, created_at FROM permissions ORDER BY name`)
+	if err != nil {
+		log.Printf("Error listing permissions: %v", err)
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// CreatePermission stores a new permission.
+// It reports false when a permission with that name already exists.
+func (r *RoleRepository) CreatePermission(permission *models.Permission) (bool, error) {
+	query := `
+		INSERT INTO permissions (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRow(query, permission.Name, permission.Description).Scan(&permission.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		log.Printf("Error creating permission: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeletePermission removes a permission from every role and reports whether it existed
+func (r *RoleRepository) DeletePermission(name string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM permissions WHERE name = $1`, name)
+	if err != nil {
+		log.Printf("Error deleting permission: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// GetUserAccess returns the roles of a user and the permissions they grant
+func (r *RoleRepository) GetUserAccess(userID uuid.UUID) (*models.UserAccess, error) {
+	access := &models.UserAccess{Roles: []string{}, Permissions: []string{}}
+
+	err := r.db.Select(&access.Roles, `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`, userID)
+	if err != nil {
+		log.Printf("Error getting user roles: %v", err)
+		return nil, err
+	}
+
+	query := `
+		SELECT DISTINCT rp.permission
+		FROM user_roles ur
+		JOIN role_permissions rp ON rp.role = ur.role
+		WHERE ur.user_id = $1
+		ORDER BY rp.permission
+	`
+	err = r.db.Select(&access.Permissions, query, userID)
+	if err != nil {
+		log.Printf("Error getting user permissions: %v", err)
+		return nil, err
+	}
+
+	return access, nil
+}
+
+// SetUserRoles replaces the roles of a user.
+// The primary role in users.role is kept if the user still holds it, otherwise it becomes the first of the new roles.
+func (r *RoleRepository) SetUserRoles(userID uuid.UUID, roles []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_roles WHERE user_id = $1`, userID); err != nil {
+		log.Printf("Error clearing user roles: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_roles (user_id, role)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING
+	`, userID, pq.Array(roles))
+	if err != nil {
+		log.Printf("Error assigning user roles: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET role = CASE WHEN role = ANY($2::text[]) THEN role ELSE COALESCE(($2::text[])[1], '') END
+		WHERE id = $1
+	`, userID, pq.Array(roles))
+	if err != nil {
+		log.Printf("Error updating primary role: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing user roles: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,403 @@
+package repository
+
+import (
+	"log"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// TokenRepository provides access to the refresh token storage
+type TokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewTokenRepository creates a new TokenRepository instance
+func NewTokenRepository(db *sqlx.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// CreateFamily creates a new refresh token family, recording the session it belongs to
+func (r *TokenRepository) CreateFamily(session *models.Session) (uuid.UUID, error) {
+	var familyID uuid.UUID
+	query := `
+		INSERT INTO refresh_token_families (user_id, client_id, device_name, user_agent, ip)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	err := r.db.QueryRow(query, session.UserID, session.ClientID, session.DeviceName, session.UserAgent, session.IP).Scan(&familyID)
+	if err != nil {
+		log.Printf("Error creating token family: %v", err)
+		return uuid.Nil, err
+	}
+
+	return familyID, nil
+}
+
+// SaveRefreshToken stores a newly issued refresh token
+func (r *TokenRepository) SaveRefreshToken(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, family_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(query, token.ID, token.FamilyID, token.UserID, token.ExpiresAt)
+	if err != nil {
+		log.Printf("Error saving refresh token: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetRefreshToken retrieves a refresh token by ID together with the state of its family
+func (r *TokenRepository) GetRefreshToken(id uuid.UUID) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	query := `
+		SELECT t.id, t.family_id, t.user_id, t.expires_at, t.rotated_at, t.created_at,
+		       f.revoked_at AS family_revoked_at
+		FROM refresh_tokens t
+		JOIN refresh_token_families f ON f.id = t.family_id
+		WHERE t.id = $1
+	`
+
+	err := r.db.Get(&token, query, id)
+	if err != nil {
+		log.Printf("Error getting refresh token: %v", err)
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken marks the old refresh token as used and stores its replacement.
+// It returns false if the old token has already been rotated.
+func (r *TokenRepository) RotateRefreshToken(oldID uuid.UUID, next *models.RefreshToken) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Only one caller can rotate a given token
+	result, err := tx.Exec(`UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = $1 AND rotated_at IS NULL`, oldID)
+	if err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (id, family_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, next.ID, next.FamilyID, next.UserID, next.ExpiresAt)
+	if err != nil {
+		log.Printf("Error saving rotated refresh token: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing token rotation: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RevokeFamily revokes a refresh token family, invalidating every token in it
+func (r *TokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	query := `UPDATE refresh_token_families SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(query, familyID)
+	if err != nil {
+		log.Printf("Error revoking token family: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// TouchFamily records that a session has just been used, and from where.
+// Empty values keep the address and user agent seen before.
+func (r *TokenRepository) TouchFamily(familyID uuid.UUID, ip, userAgent string) error {
+	query := `
+		UPDATE refresh_token_families
+		SET last_seen_at = NOW(),
+		    ip = COALESCE(NULLIF($2, ''), ip),
+		    user_agent = COALESCE(NULLIF($3, ''), user_agent)
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(query, familyID, ip, userAgent)
+	if err != nil {
+		log.Printf("Error updating session: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListActiveFamilies returns the sessions of a user that are neither revoked nor expired, most recently used first
+func (r *TokenRepository) ListActiveFamilies(userID uuid.UUID) ([]models.Session, error) {
+	sessions := []models.Session{}
+	query := `
+		SELECT f.id, f.user_id, f.client_id, f.device_name, f.user_agent, f.ip, f.created_at,
+		       COALESCE(f.last_seen_at, f.created_at) AS last_seen_at
+		FROM refresh_token_families f
+		WHERE f.user_id = $1 AND f.revoked_at IS NULL
+		  AND EXISTS(SELECT 1 FROM refresh_tokens t
+		             WHERE t.family_id = f.id AND t.rotated_at IS NULL AND t.expires_at > NOW())
+		ORDER BY last_seen_at DESC
+	`
+
+	err := r.db.Select(&sessions, query, userID)
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserFamily revokes one of a user's refresh token families.
+// It reports false if the family does not belong to the user or is already revoked.
+func (r *TokenRepository) RevokeUserFamily(userID, familyID uuid.UUID) (bool, error) {
+	query := `UPDATE refresh_token_families SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, familyID, userID)
+	if err != nil {
+		log.Printf("Error revoking token family: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// RevokeUserFamilies revokes the refresh token families of a user, signing them out everywhere.
+// The family given in keep stays valid; pass uuid.Nil to revoke all of them.
+func (r *TokenRepository) RevokeUserFamilies(userID, keep uuid.UUID) error {
+	query := `UPDATE refresh_token_families SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(query, userID, keep)
+	if err != nil {
+		log.Printf("Error revoking token families of user: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAccessToken records an access token as revoked until it expires
+func (r *TokenRepository) RevokeAccessToken(tokenID string, userID uuid.UUID, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query, tokenID, userID, expiresAt)
+	if err != nil {
+		log.Printf("Error revoking access token: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token was revoked directly or through its token family
+func (r *TokenRepository) IsTokenRevoked(tokenID, familyID string) (bool, error) {
+	// Tokens without a valid family only need the direct check
+	var family interface{}
+	if id, err := uuid.Parse(familyID); err == nil {
+		family = id
+	}
+
+	var revoked bool
+	query := `
+		SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR EXISTS(SELECT 1 FROM refresh_token_families WHERE id = $2 AND revoked_at IS NOT NULL)
+	`
+
+	err := r.db.QueryRow(query, tokenID, family).Scan(&revoked)
+	if err != nil {
+		log.Printf("Error checking token revocation: %v", err)
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// PurgeExpiredRevocations removes revocation records for tokens that have expired anyway
+func (r *TokenRepository) PurgeExpiredRevocations() error {
+	_, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		log.Printf("Error purging expired revocations: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// SaveAuthorizationCode stores a newly issued authorization code
+func (r *TokenRepository) SaveAuthorizationCode(code *models.AuthorizationCode) error {
+	query := `
+		INSERT INTO authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, nonce,
+		                                 code_challenge, code_challenge_method, auth_time, expires_at, amr)
+		VALUES (:code_hash, :client_id, :user_id, :redirect_uri, :scope, :nonce,
+		        :code_challenge, :code_challenge_method, :auth_time, :expires_at, :amr)
+	`
+
+	_, err := r.db.NamedExec(query, code)
+	if err != nil {
+		log.Printf("Error saving authorization code: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAuthorizationCode retrieves an authorization code by the hash of its value
+func (r *TokenRepository) GetAuthorizationCode(codeHash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	query := `
+		SELECT code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge,
+		       code_challenge_method, auth_time, expires_at, used_at, family_id, amr, created_at
+		FROM authorization_codes
+		WHERE code_hash = $1
+	`
+
+	err := r.db.Get(&code, query, codeHash)
+	if err != nil {
+		log.Printf("Error getting authorization code: %v", err)
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// UseAuthorizationCode marks a code as used and reports false if it had already been used
+func (r *TokenRepository) UseAuthorizationCode(codeHash string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE authorization_codes SET used_at = NOW() WHERE code_hash = $1 AND used_at IS NULL`, codeHash)
+	if err != nil {
+		log.Printf("Error using authorization code: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// SetAuthorizationCodeFamily links a used code to the token family issued for it
+func (r *TokenRepository) SetAuthorizationCodeFamily(codeHash string, familyID uuid.UUID) error {
+	_, err := r.db.Exec(`UPDATE authorization_codes SET family_id = $2 WHERE code_hash = $1`, codeHash, familyID)
+	if err != nil {
+		log.Printf("Error linking authorization code to token family: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// PurgeExpiredAuthorizationCodes removes codes that expired more than a day ago.
+// Used codes are kept for a while so that replays can still be detected.
+func (r *TokenRepository) PurgeExpiredAuthorizationCodes() error {
+	_, err := r.db.Exec(`DELETE FROM authorization_codes WHERE expires_at < NOW() - INTERVAL '1 day'`)
+	if err != nil {
+		log.Printf("Error purging expired authorization codes: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// SavePasswordResetToken stores the hash of a newly issued password reset token
+func (r *TokenRepository) SavePasswordResetToken(userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	query := `INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+
+	_, err := r.db.Exec(query, tokenHash, userID, expiresAt)
+	if err != nil {
+		log.Printf("Error saving password reset token: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetPasswordResetTokenUser returns the user of an unexpired, unused reset token without using it.
+// It returns sql.ErrNoRows if the token is unknown, expired or already used.
+func (r *TokenRepository) GetPasswordResetTokenUser(tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	query := `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	err := r.db.QueryRow(query, tokenHash).Scan(&userID)
+	if err != nil {
+		log.Printf("Error getting password reset token: %v", err)
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+// UsePasswordResetToken marks an unexpired reset token as used and returns its user.
+// It returns sql.ErrNoRows if the token is unknown, expired or already used.
+func (r *TokenRepository) UsePasswordResetToken(tokenHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	query := `
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	err := r.db.QueryRow(query, tokenHash).Scan(&userID)
+	if err != nil {
+		log.Printf("Error using password reset token: %v", err)
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+// DeletePasswordResetTokens removes the outstanding reset tokens of a user
+func (r *TokenRepository) DeletePasswordResetTokens(userID uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		log.Printf("Error deleting password reset tokens: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// PurgeExpiredPasswordResetTokens removes reset tokens that have expired anyway
+func (r *TokenRepository) PurgeExpiredPasswordResetTokens() error {
+	_, err := r.db.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		log.Printf("Error purging expired password reset tokens: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"log"
+	"time"
 
 	"github.com/diplom/auth-service/internal/models"
 	"github.com/google/uuid"
@@ -19,13 +20,18 @@ func NewUserRepository(db *sqlx.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-// CreateUser creates a new user in the database
+// CreateUser creates a new user in the database with the default role
 func (r *UserRepository) CreateUser(email, passwordHash string) (uuid.UUID, error) {
 	var userID uuid.UUID
 	query := `
-		INSERT INTO users (email, password_hash) 
-		VALUES ($1, $2) 
-		RETURNING id
+		WITH created AS (
+			INSERT INTO users (email, password_hash)
+			VALUES ($1, $2)
+			RETURNING id, role
+		)
+		INSERT INTO user_roles (user_id, role)
+		SELECT id, role FROM created
+		RETURNING user_id
 	`
 
 	err := r.db.QueryRow(query, email, passwordHash).Scan(&userID)
@@ -40,7 +46,7 @@ func (r *UserRepository) CreateUser(email, passwordHash string) (uuid.UUID, erro
 // GetUserByID retrieves a user by ID
 func (r *UserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, email, password_hash, role, created_at FROM users WHERE id = $1`
+	query := `SELECT id, email, email_verified, name, password_hash, role, created_at, last_login_at FROM users WHERE id = $1`
 
 	err := r.db.Get(&user, query, id)
 	if err != nil {
@@ -54,7 +60,7 @@ func (r *UserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
 // GetUserByEmail retrieves a user by email
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, email, password_hash, role, created_at FROM users WHERE email = $1`
+	query := `SELECT id, email, email_verified, name, password_hash, role, created_at, last_login_at FROM users WHERE email = $1`
 
 	err := r.db.Get(&user, query, email)
 	if err != nil {
@@ -79,6 +85,149 @@ func (r *UserRepository) UserExists(email string) (bool, error) {
 	return exists, nil
 }
 
+// SetEmailVerified marks the email address of a user as verified.
+// It reports false when the user no longer has that address.
+func (r *UserRepository) SetEmailVerified(userID uuid.UUID, email string) (bool, error) {
+	query := `UPDATE users SET email_verified = TRUE WHERE id = $1 AND email = $2`
+
+	result, err := r.db.Exec(query, userID, email)
+	if err != nil {
+		log.Printf("Error verifying email: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// UpdatePassword replaces the password hash of a user
+func (r *UserRepository) UpdatePassword(userID uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2 WHERE id = $1`
+
+	_, err := r.db.Exec(query, userID, passwordHash)
+	if err != nil {
+		log.Printf("Error updating password: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash swaps the stored password hash only while it still equals oldHash.
+// It reports false when the password has been changed in the meantime.
+func (r *UserRepository) ReplacePasswordHash(userID uuid.UUID, oldHash, newHash string) (bool, error) {
+	query := `UPDATE users SET password_hash = $3 WHERE id = $1 AND password_hash = $2`
+
+	result, err := r.db.Exec(query, userID, oldHash, newHash)
+	if err != nil {
+		log.Printf("Error replacing password hash: %v", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// UpdateName replaces the display name of a user
+func (r *UserRepository) UpdateName(userID uuid.UUID, name string) error {
+	query := `UPDATE users SET name = $2 WHERE id = $1`
+
+	_, err := r.db.Exec(query, userID, name)
+	if err != nil {
+		log.Printf("Error updating name: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateEmail replaces the email address of a user and marks it as unverified
+func (r *UserRepository) UpdateEmail(userID uuid.UUID, email string) error {
+	query := `UPDATE users SET email = $2, email_verified = FALSE WHERE id = $1`
+
+	_, err := r.db.Exec(query, userID, email)
+	if err != nil {
+		log.Printf("Error updating email: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RecordLogin adds an entry to the login history of a user.
+// A successful login also becomes the user's last login.
+func (r *UserRepository) RecordLogin(entry *models.LoginHistoryEntry) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExec(`
+		INSERT INTO login_history (user_id, success, methods, reason, client_id, device_name, user_agent, ip)
+		VALUES (:user_id, :success, :methods, :reason, :client_id, :device_name, :user_agent, :ip)
+	`, entry)
+	if err != nil {
+		log.Printf("Error recording login: %v", err)
+		return err
+	}
+
+	if entry.Success {
+		_, err = tx.Exec(`UPDATE users SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1`, entry.UserID)
+		if err != nil {
+			log.Printf("Error updating last login: %v", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing login: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListLoginHistory returns the most recent logins of a user, newest first
+func (r *UserRepository) ListLoginHistory(userID uuid.UUID, limit int) ([]models.LoginHistoryEntry, error) {
+	query := `
+		SELECT id, user_id, success, methods, reason, client_id, device_name, user_agent, ip, created_at
+		FROM login_history
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	entries := []models.LoginHistoryEntry{}
+	err := r.db.Select(&entries, query, userID, limit)
+	if err != nil {
+		log.Printf("Error listing login history: %v", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// PurgeLoginHistory deletes login history entries older than the retention period
+func (r *UserRepository) PurgeLoginHistory(retention time.Duration) error {
+	_, err := r.db.Exec(`DELETE FROM login_history WHERE created_at < NOW() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		log.Printf("Error purging login history: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 // InitDatabase initializes the database schema
 func InitDatabase(db *sqlx.DB) error {
 	schema := `
@@ -89,6 +238,235 @@ func InitDatabase(db *sqlx.DB) error {
 		role VARCHAR(50) NOT NULL DEFAULT 'user',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS name VARCHAR(255) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS login_history (
+		id BIGSERIAL PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		success BOOLEAN NOT NULL,
+		methods TEXT[] NOT NULL DEFAULT '{}',
+		reason TEXT NOT NULL DEFAULT '',
+		client_id TEXT NOT NULL DEFAULT '',
+		device_name TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_login_history_user_id ON login_history(user_id, id);
+	CREATE INDEX IF NOT EXISTS idx_login_history_created_at ON login_history(created_at);
+
+	CREATE TABLE IF NOT EXISTS roles (
+		name VARCHAR(50) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS permissions (
+		name VARCHAR(100) PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+		permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+		PRIMARY KEY (role, permission)
+	);
+
+	CREATE TABLE IF NOT EXISTS user_roles (
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, role)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles(role);
+
+	INSERT INTO roles (name, description) VALUES
+		('user', 'Default role of every registered user'),
+		('admin', 'Access to the administrative API')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO roles (name) SELECT DISTINCT role FROM users WHERE role <> '' ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO user_roles (user_id, role)
+	SELECT id, role FROM users
+	WHERE role <> '' AND NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id);
+
+	CREATE TABLE IF NOT EXISTS refresh_token_families (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	ALTER TABLE refresh_token_families ADD COLUMN IF NOT EXISTS client_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE refresh_token_families ADD COLUMN IF NOT EXISTS device_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE refresh_token_families ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
+	ALTER TABLE refresh_token_families ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '';
+	ALTER TABLE refresh_token_families ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+	CREATE INDEX IF NOT EXISTS idx_refresh_token_families_user_id ON refresh_token_families(user_id);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id UUID PRIMARY KEY,
+		family_id UUID NOT NULL REFERENCES refresh_token_families(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP NOT NULL,
+		rotated_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		user_id UUID NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS signing_keys (
+		id TEXT PRIMARY KEY,
+		algorithm VARCHAR(16) NOT NULL,
+		key_material TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		retired_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS oauth_clients (
+		id TEXT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		secret_hash TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS public BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS allowed_scopes TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS audience TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS authorization_codes (
+		code_hash TEXT PRIMARY KEY,
+		client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		redirect_uri TEXT NOT NULL,
+		scope TEXT NOT NULL DEFAULT '',
+		nonce TEXT NOT NULL DEFAULT '',
+		code_challenge TEXT NOT NULL,
+		code_challenge_method VARCHAR(10) NOT NULL,
+		auth_time TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		family_id UUID,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS amr TEXT[] NOT NULL DEFAULT '{}';
+
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		token_hash TEXT PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		scope VARCHAR(16) NOT NULL,
+		key TEXT NOT NULL,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP,
+		PRIMARY KEY (scope, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		event TEXT NOT NULL,
+		outcome TEXT NOT NULL,
+		actor_id TEXT NOT NULL DEFAULT '',
+		subject_id UUID,
+		subject_email TEXT NOT NULL DEFAULT '',
+		client_id TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_events_subject_id ON audit_events(subject_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+
+	CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_events_append_only') THEN
+			CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+				FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+		END IF;
+	END $$;
+
+	CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+		key TEXT PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		allowed BOOLEAN NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS mfa_totp (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		secret TEXT NOT NULL,
+		last_counter BIGINT NOT NULL DEFAULT 0,
+		enabled_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS webauthn_credentials (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		credential_id BYTEA UNIQUE NOT NULL,
+		public_key BYTEA NOT NULL,
+		sign_count BIGINT NOT NULL DEFAULT 0,
+		aaguid BYTEA NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		transports TEXT[] NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash TEXT NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+
+	CREATE TABLE IF NOT EXISTS webauthn_sessions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+		ceremony VARCHAR(16) NOT NULL,
+		challenge TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	_, err := db.Exec(schema)
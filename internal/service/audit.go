@@ -0,0 +1,88 @@
+package service
+
+import (
+	"log"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 500
+)
+
+// Audit reasons shared by several events
+const (
+	auditReasonUnknownUser      = "unknown_user"
+	auditReasonInvalidPassword  = "invalid_password"
+	auditReasonLocked           = "locked"
+	auditReasonEmailNotVerified = "email_not_verified"
+	auditReasonInvalidMFACode   = "invalid_mfa_code"
+	auditReasonEmailTaken       = "email_taken"
+	auditReasonTokenRevoked     = "token_revoked"
+	auditReasonTokenReused      = "token_reused"
+	auditReasonReuseDetected    = "reuse_detected"
+	auditReasonLogout           = "logout"
+	auditReasonOAuthRevoke      = "oauth_revoke"
+)
+
+// ListAuditEvents returns a page of the audit log, newest first
+func (s *AuthService) ListAuditEvents(filter models.AuditEventFilter) (*models.AuditEventsResponse, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+
+	events, err := s.auditRepo.ListEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.AuditEventsResponse{Events: events}
+	if len(events) == filter.Limit {
+		response.NextBefore = events[len(events)-1].ID
+	}
+
+	return response, nil
+}
+
+// userAuditEvent starts an audit event about a user's account
+func userAuditEvent(event, outcome string, user *models.User) models.AuditEvent {
+	return models.AuditEvent{
+		Event:        event,
+		Outcome:      outcome,
+		SubjectID:    &user.ID,
+		SubjectEmail: user.Email,
+	}
+}
+
+// subjectAuditEvent starts an audit event about an account known only by its ID
+func subjectAuditEvent(event, outcome string, userID uuid.UUID) models.AuditEvent {
+	return models.AuditEvent{
+		Event:     event,
+		Outcome:   outcome,
+		SubjectID: &userID,
+	}
+}
+
+// recordAudit appends an event with the caller's details to the audit log.
+// Without an authenticated caller a successful action is attributed to its subject,
+// who proved control of the account with a password or a token.
+// Write failures are logged and never fail the request.
+func (s *AuthService) recordAudit(event models.AuditEvent, info RequestInfo) {
+	if event.ActorID == "" {
+		event.ActorID = info.ActorID
+	}
+	if event.ActorID == "" && event.Outcome == models.AuditSuccess && event.SubjectID != nil {
+		event.ActorID = event.SubjectID.String()
+	}
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+
+	if err := s.auditRepo.Record(&event); err != nil {
+		log.Printf("Error recording %s audit event: %v", event.Event, err)
+	}
+}
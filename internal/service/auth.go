@@ -0,0 +1,489 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	netmail "net/mail"
+
+	"github.com/diplom/auth-service/internal/mail"
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/passhash"
+	"github.com/diplom/auth-service/internal/passpolicy"
+	"github.com/diplom/auth-service/internal/repository"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// AuthService implements the authentication logic shared by the HTTP and gRPC APIs
+type AuthService struct {
+	userRepo       userStore
+	tokenRepo      tokenStore
+	clientRepo     clientStore
+	mfaRepo        mfaStore
+	attemptRepo    loginAttemptStore
+	auditRepo      auditStore
+	roleRepo       roleStore
+	mailer         mail.Mailer
+	hasher         *passhash.Hasher
+	passwordPolicy *passpolicy.Policy
+	userCache      *lookupCache[uuid.UUID, models.User]
+	clientCache    *lookupCache[string, models.OAuthClient]
+	accessCache    *lookupCache[uuid.UUID, models.UserAccess]
+}
+
+// NewAuthService creates a new AuthService instance
+func NewAuthService(userRepo *repository.UserRepository, tokenRepo *repository.TokenRepository,
+	clientRepo *repository.ClientRepository, mfaRepo *repository.MFARepository,
+	attemptRepo *repository.LoginAttemptRepository, auditRepo *repository.AuditRepository,
+	roleRepo *repository.RoleRepository, mailer mail.Mailer, hasher *passhash.Hasher,
+	passwordPolicy *passpolicy.Policy) *AuthService {
+	return &AuthService{
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		clientRepo:     clientRepo,
+		mfaRepo:        mfaRepo,
+		attemptRepo:    attemptRepo,
+		auditRepo:      auditRepo,
+		roleRepo:       roleRepo,
+		mailer:         mailer,
+		hasher:         hasher,
+		passwordPolicy: passwordPolicy,
+		userCache:      newLookupCache(userRepo.GetUserByID, lookupCacheTTL),
+		clientCache:    newLookupCache(clientRepo.GetClient, lookupCacheTTL),
+		accessCache:    newLookupCache(roleRepo.GetUserAccess, lookupCacheTTL),
+	}
+}
+
+// AuthResult is the outcome of a successful registration or login.
+// When MFA is set, no tokens were issued and the login must be completed with CompleteMFALogin.
+// Registrations that still need email verification carry no tokens either.
+type AuthResult struct {
+	User   *models.User
+	Tokens utils.TokenPair
+	MFA    *MFAChallenge
+}
+
+// RequestInfo describes where a request comes from.
+// DeviceName is an optional name the client gives itself for the session list.
+// ActorID identifies an authenticated caller for the audit log.
+type RequestInfo struct {
+	IP         string
+	UserAgent  string
+	DeviceName string
+	ActorID    string
+}
+
+// Register creates a new user, sends the email verification link and issues the first token pair.
+// No tokens are issued while unverified users are not allowed to log in.
+func (s *AuthService) Register(email, password string, info RequestInfo) (*AuthResult, error) {
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := s.validatePassword(password, email); err != nil {
+		return nil, err
+	}
+
+	// Check if user already exists
+	exists, err := s.userRepo.UserExists(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		s.recordAudit(models.AuditEvent{
+			Event:        models.AuditUserRegister,
+			Outcome:      models.AuditFailure,
+			SubjectEmail: email,
+			Reason:       auditReasonEmailTaken,
+		}, info)
+		return nil, ErrUserExists
+	}
+
+	// Hash the password
+	hashedPassword, err := s.hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the user
+	userID, err := s.userRepo.CreateUser(email, hashedPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get full user details
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAudit(userAuditEvent(models.AuditUserRegister, models.AuditSuccess, user), info)
+
+	s.sendVerificationEmail(user)
+	if checkEmailVerified(user) != nil {
+		return &AuthResult{User: user}, nil
+	}
+
+	// Generate tokens
+	tokenPair, err := s.issueTokens(user, utils.TokenOptions{AMR: []string{utils.AMRPassword}}, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	return &AuthResult{User: user, Tokens: tokenPair}, nil
+}
+
+// Login verifies the user's credentials and issues a token pair.
+// Users with a second factor get an MFA challenge instead of tokens.
+func (s *AuthService) Login(email, password string, info RequestInfo) (*AuthResult, error) {
+	user, err := s.authenticateUser(email, password, info)
+	if err != nil {
+		return nil, err
+	}
+
+	amr := []string{utils.AMRPassword}
+	challenge, err := s.mfaChallenge(user, amr)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &AuthResult{User: user, MFA: challenge}, nil
+	}
+
+	// Generate tokens
+	tokenPair, err := s.issueTokens(user, utils.TokenOptions{AMR: amr}, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	return &AuthResult{User: user, Tokens: tokenPair}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+// Every refresh token can be used only once; replaying a rotated token revokes its whole family.
+func (s *AuthService) Refresh(refreshToken string, info RequestInfo) (utils.TokenPair, error) {
+	if refreshToken == "" {
+		return utils.TokenPair{}, fmt.Errorf("%w: refresh token is required", ErrInvalidArgument)
+	}
+
+	// Validate the refresh token signature and claims
+	claims, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		log.Printf("Invalid refresh token: %v", err)
+		return utils.TokenPair{}, ErrInvalidToken
+	}
+
+	// Tokens issued to OAuth clients can only be refreshed by that client at the token endpoint
+	if claims.ClientID != "" {
+		log.Printf("Refresh token of client %s presented without client authentication", claims.ClientID)
+		return utils.TokenPair{}, ErrInvalidToken
+	}
+
+	result, err := s.rotateRefreshToken(claims, info)
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	return result.Tokens, nil
+}
+
+// rotateRefreshToken replaces a parsed refresh token with a new token pair in the same family.
+// The new pair keeps the options the refresh token was issued with.
+func (s *AuthService) rotateRefreshToken(claims *utils.TokenClaims, info RequestInfo) (*AuthResult, error) {
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		log.Printf("Invalid refresh token ID: %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	// Look up the server-side record of the token
+	stored, err := s.tokenRepo.GetRefreshToken(tokenID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if stored.FamilyRevokedAt != nil {
+		event := subjectAuditEvent(models.AuditTokenRefresh, models.AuditFailure, stored.UserID)
+		event.Reason = auditReasonTokenRevoked
+		s.recordAudit(event, info)
+		return nil, ErrTokenRevoked
+	}
+
+	// A token that was already rotated is being replayed, so the family is compromised
+	if stored.RotatedAt != nil {
+		s.revokeReusedFamily(stored, info)
+		return nil, ErrTokenReused
+	}
+
+	// Load the user to pick up the current roles and permissions
+	user, err := s.userRepo.GetUserByID(stored.UserID)
+	if err != nil {
+		log.Printf("Error fetching user for refresh: %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	access, err := s.roleRepo.GetUserAccess(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate a new token pair within the same family
+	tokenPair, err := utils.GenerateTokenPair(user.ID, user.Email, user.Role, *access, stored.FamilyID, claims.Options())
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	// Rotate the old token; losing the race means someone else already used it
+	rotated, err := s.tokenRepo.RotateRefreshToken(stored.ID, &models.RefreshToken{
+		ID:        tokenPair.RefreshTokenID,
+		FamilyID:  stored.FamilyID,
+		UserID:    user.ID,
+		ExpiresAt: tokenPair.RefreshExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !rotated {
+		s.revokeReusedFamily(stored, info)
+		return nil, ErrTokenReused
+	}
+
+	// The session list shows when and where each session was last active
+	if err := s.tokenRepo.TouchFamily(stored.FamilyID, info.IP, info.UserAgent); err != nil {
+		log.Printf("Error recording session activity: %v", err)
+	}
+
+	event := userAuditEvent(models.AuditTokenRefresh, models.AuditSuccess, user)
+	event.ClientID = claims.ClientID
+	s.recordAudit(event, info)
+
+	return &AuthResult{User: user, Tokens: tokenPair}, nil
+}
+
+// Logout revokes an access token and its refresh token family.
+// An optional refresh token owned by the same user has its family revoked too.
+func (s *AuthService) Logout(claims *utils.TokenClaims, refreshToken string, info RequestInfo) error {
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	// Revoke the access token itself
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.tokenRepo.RevokeAccessToken(claims.ID, userID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+
+	// Revoke the refresh token family the access token was issued in
+	if familyID, err := uuid.Parse(claims.FamilyID); err == nil {
+		if err := s.tokenRepo.RevokeFamily(familyID); err != nil {
+			return err
+		}
+	}
+
+	// Revoke the family of an explicitly provided refresh token owned by the same user
+	if refreshToken != "" {
+		refreshClaims, err := utils.ParseRefreshToken(refreshToken)
+		if err == nil && refreshClaims.UserID == claims.UserID {
+			if familyID, err := uuid.Parse(refreshClaims.FamilyID); err == nil {
+				if err := s.tokenRepo.RevokeFamily(familyID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	event := subjectAuditEvent(models.AuditTokenRevoke, models.AuditSuccess, userID)
+	event.SubjectEmail = claims.Email
+	event.ClientID = claims.ClientID
+	event.Reason = auditReasonLogout
+	s.recordAudit(event, info)
+
+	return nil
+}
+
+// LogoutWithToken validates an access token and logs its session out
+func (s *AuthService) LogoutWithToken(accessToken, refreshToken string, info RequestInfo) error {
+	if accessToken == "" {
+		return fmt.Errorf("%w: access token is required", ErrInvalidArgument)
+	}
+
+	claims, err := utils.ParseTokenClaims(accessToken)
+	if err != nil {
+		log.Printf("Access token rejected: %v", err)
+		return ErrInvalidToken
+	}
+
+	return s.Logout(claims, refreshToken, info)
+}
+
+// GetUser returns a user by ID
+func (s *AuthService) GetUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ScopeUsersRead lets a service token look up any user
+const ScopeUsersRead = "users:read"
+
+// LookupUser returns a user to the holder of an access token: the user themselves,
+// or a service whose token was granted the users:read scope
+func (s *AuthService) LookupUser(claims *utils.TokenClaims, userID uuid.UUID) (*models.User, error) {
+	if claims.IsService() {
+		if !utils.HasScope(claims.Scope, ScopeUsersRead) {
+			return nil, ErrInsufficientScope
+		}
+	} else if claims.UserID != userID.String() {
+		return nil, ErrPermissionDenied
+	}
+
+	return s.GetUser(userID)
+}
+
+// authenticateUser verifies an email and password and returns the matching user.
+// Repeated failures for the account or the source address block further attempts for a while.
+func (s *AuthService) authenticateUser(email, password string, info RequestInfo) (*models.User, error) {
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, fmt.Errorf("%w: password is required", ErrInvalidArgument)
+	}
+
+	// Locked logins are rejected before the password is checked, so guesses reveal nothing
+	if err := s.checkLoginAllowed(email, info); err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			s.recordLoginAudit(email, auditReasonLocked, info)
+		}
+		return nil, err
+	}
+
+	// Get user by email
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.recordLoginFailure(email, info)
+			s.recordLoginAudit(email, auditReasonUnknownUser, info)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// Compare passwords
+	rehash, err := s.checkPassword(user, password)
+	if err != nil {
+		s.recordLoginFailure(email, info)
+		s.recordLogin(user, models.LoginHistoryEntry{Reason: auditReasonInvalidPassword}, info)
+		return nil, err
+	}
+
+	// Move the user to the current hash settings while the plain password is at hand
+	if rehash {
+		s.upgradePasswordHash(user, password)
+	}
+
+	if err := checkEmailVerified(user); err != nil {
+		s.recordLogin(user, models.LoginHistoryEntry{Reason: auditReasonEmailNotVerified}, info)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// issueTokens starts a new session, a refresh token family for the user, and issues the first token pair in it
+func (s *AuthService) issueTokens(user *models.User, opts utils.TokenOptions, info RequestInfo) (utils.TokenPair, error) {
+	access, err := s.roleRepo.GetUserAccess(user.ID)
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	familyID, err := s.tokenRepo.CreateFamily(&models.Session{
+		UserID:     user.ID,
+		ClientID:   opts.ClientID,
+		DeviceName: utils.DeviceName(info.DeviceName, info.UserAgent),
+		UserAgent:  info.UserAgent,
+		IP:         info.IP,
+	})
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	tokenPair, err := utils.GenerateTokenPair(user.ID, user.Email, user.Role, *access, familyID, opts)
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	err = s.tokenRepo.SaveRefreshToken(&models.RefreshToken{
+		ID:        tokenPair.RefreshTokenID,
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: tokenPair.RefreshExpiresAt,
+	})
+	if err != nil {
+		return utils.TokenPair{}, err
+	}
+
+	// Every new session is a completed login; only now, after any second factor, are the failed logins forgotten
+	s.recordLoginSuccess(user.Email)
+	s.recordLogin(user, models.LoginHistoryEntry{Success: true, Methods: opts.AMR, ClientID: opts.ClientID}, info)
+
+	return tokenPair, nil
+}
+
+// recordLoginAudit records a failed login for an email that did not get as far as a known account
+func (s *AuthService) recordLoginAudit(email, reason string, info RequestInfo) {
+	s.recordAudit(models.AuditEvent{
+		Event:        models.AuditLogin,
+		Outcome:      models.AuditFailure,
+		SubjectEmail: email,
+		Reason:       reason,
+	}, info)
+}
+
+// revokeReusedFamily revokes the family of a refresh token that was presented more than once
+func (s *AuthService) revokeReusedFamily(token *models.RefreshToken, info RequestInfo) {
+	log.Printf("Refresh token reuse detected for user %s, revoking family %s", token.UserID, token.FamilyID)
+
+	event := subjectAuditEvent(models.AuditTokenRefresh, models.AuditFailure, token.UserID)
+	event.Reason = auditReasonTokenReused
+	s.recordAudit(event, info)
+
+	if err := s.tokenRepo.RevokeFamily(token.FamilyID); err != nil {
+		log.Printf("Error revoking token family %s: %v", token.FamilyID, err)
+		return
+	}
+
+	event = subjectAuditEvent(models.AuditTokenRevoke, models.AuditSuccess, token.UserID)
+	event.Reason = auditReasonReuseDetected
+	s.recordAudit(event, info)
+}
+
+// validateEmail checks that the value is a plain email address
+func validateEmail(email string) error {
+	if email == "" {
+		return fmt.Errorf("%w: email is required", ErrInvalidArgument)
+	}
+
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("%w: invalid email address", ErrInvalidArgument)
+	}
+
+	return nil
+}
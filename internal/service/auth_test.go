@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+func TestLookupUserDenied(t *testing.T) {
+	userID := uuid.New()
+	other := uuid.New()
+
+	tests := []struct {
+		name    string
+		claims  *utils.TokenClaims
+		wantErr error
+	}{
+		{name: "another user", claims: &utils.TokenClaims{UserID: other.String()}, wantErr: ErrPermissionDenied},
+		{name: "service without scope", claims: &utils.TokenClaims{ClientID: "reports", SubjectType: utils.SubjectTypeService, Scope: "orders:read"}, wantErr: ErrInsufficientScope},
+	}
+
+	// The service has no user repository, so a lookup that got through would panic
+	s := &AuthService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.LookupUser(tt.claims, userID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("LookupUser error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
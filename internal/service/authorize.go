@@ -0,0 +1,237 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+)
+
+// authorizationCodeTTL is how long an authorization code can be exchanged for tokens
+const authorizationCodeTTL = 2 * time.Minute
+
+// authorizationCodeBytes is the entropy of generated authorization codes
+const authorizationCodeBytes = 32
+
+// Authorization request parameters defined by RFC 6749 and RFC 7636
+const (
+	ResponseTypeCode           = "code"
+	GrantTypeAuthorizationCode = "authorization_code"
+	CodeChallengeMethodS256    = "S256"
+)
+
+// AuthorizationRequest holds the parameters of an authorization endpoint request
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// ValidateAuthorizationRequest checks the parameters that do not depend on the user.
+// PKCE with S256 is mandatory for every client.
+func (s *AuthService) ValidateAuthorizationRequest(req *AuthorizationRequest) error {
+	if req.ResponseType != ResponseTypeCode {
+		return ErrUnsupportedResponseType
+	}
+
+	scope, err := normalizeScope(req.Scope)
+	if err != nil {
+		return err
+	}
+	req.Scope = scope
+
+	if req.CodeChallenge == "" {
+		return fmt.Errorf("%w: code_challenge is required", ErrInvalidArgument)
+	}
+	if req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return fmt.Errorf("%w: code_challenge_method must be S256", ErrInvalidArgument)
+	}
+	if len(req.CodeChallenge) != base64.RawURLEncoding.EncodedLen(sha256.Size) {
+		return fmt.Errorf("%w: invalid code_challenge", ErrInvalidArgument)
+	}
+
+	return nil
+}
+
+// AuthorizationResult is the outcome of the hosted login; either Code or MFA is set
+type AuthorizationResult struct {
+	Code string
+	MFA  *MFAChallenge
+}
+
+// Authorize authenticates the user on the hosted login page and issues an authorization code.
+// The request must already have passed ValidateAuthorizationRequest and redirect URI resolution.
+// Users with a second factor get an MFA challenge to complete with AuthorizeWithMFA.
+func (s *AuthService) Authorize(client *models.OAuthClient, req *AuthorizationRequest, email, password string, info RequestInfo) (*AuthorizationResult, error) {
+	user, err := s.authenticateUser(email, password, info)
+	if err != nil {
+		return nil, err
+	}
+
+	amr := []string{utils.AMRPassword}
+	challenge, err := s.mfaChallenge(user, amr)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &AuthorizationResult{MFA: challenge}, nil
+	}
+
+	code, err := s.createAuthorizationCode(client, req, user, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizationResult{Code: code}, nil
+}
+
+// AuthorizeWithMFA completes the hosted login with the second factor and issues an authorization code
+func (s *AuthService) AuthorizeWithMFA(client *models.OAuthClient, req *AuthorizationRequest, mfaToken, mfaCode string, info RequestInfo) (string, error) {
+	user, amr, err := s.verifySecondFactor(mfaToken, mfaCode, info)
+	if err != nil {
+		return "", err
+	}
+
+	return s.createAuthorizationCode(client, req, user, amr)
+}
+
+// AuthorizeWithWebAuthn issues an authorization code after a WebAuthn assertion on the login page.
+// Without mfaToken the assertion is a passkey login; with it, a security key completes a password login.
+func (s *AuthService) AuthorizeWithWebAuthn(client *models.OAuthClient, req *AuthorizationRequest, mfaToken, sessionID string, credential *models.PublicKeyCredential) (string, error) {
+	var user *models.User
+	var amr []string
+	var err error
+	if mfaToken == "" {
+		user, amr, err = s.authenticatePasskey(sessionID, credential)
+	} else {
+		user, amr, err = s.verifyWebAuthnSecondFactor(mfaToken, sessionID, credential)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return s.createAuthorizationCode(client, req, user, amr)
+}
+
+// createAuthorizationCode stores a new authorization code for the user and returns its value
+func (s *AuthService) createAuthorizationCode(client *models.OAuthClient, req *AuthorizationRequest, user *models.User, amr []string) (string, error) {
+	code, err := utils.RandomToken(authorizationCodeBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// Only a hash of the code is stored, like refresh tokens it is a bearer credential
+	now := time.Now()
+	err = s.tokenRepo.SaveAuthorizationCode(&models.AuthorizationCode{
+		CodeHash:            utils.HashToken(code),
+		ClientID:            client.ID,
+		UserID:              user.ID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            now,
+		ExpiresAt:           now.Add(authorizationCodeTTL),
+		AMR:                 amr,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// AuthorizationCodeGrant exchanges an authorization code and its PKCE verifier for tokens.
+// Replaying a used code revokes the tokens that were issued for it.
+func (s *AuthService) AuthorizationCodeGrant(client *models.OAuthClient, code, redirectURI, codeVerifier string, info RequestInfo) (*OAuthTokenResult, error) {
+	if code == "" {
+		return nil, fmt.Errorf("%w: code is required", ErrInvalidArgument)
+	}
+	if codeVerifier == "" {
+		return nil, fmt.Errorf("%w: code_verifier is required", ErrInvalidArgument)
+	}
+
+	codeHash := utils.HashToken(code)
+	stored, err := s.tokenRepo.GetAuthorizationCode(codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	if stored.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: authorization code was issued to another client", ErrInvalidGrant)
+	}
+	if stored.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("%w: redirect_uri does not match the authorization request", ErrInvalidGrant)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, fmt.Errorf("%w: authorization code has expired", ErrInvalidGrant)
+	}
+	if !verifyCodeChallenge(stored.CodeChallenge, codeVerifier) {
+		return nil, fmt.Errorf("%w: code_verifier does not match code_challenge", ErrInvalidGrant)
+	}
+
+	// Only the client holding the verifier may burn the code, so another client cannot invalidate it.
+	// Marking it used is atomic, so it can be exchanged only once.
+	used, err := s.tokenRepo.UseAuthorizationCode(codeHash)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		log.Printf("Authorization code reuse detected for client %s", stored.ClientID)
+		if stored.FamilyID != nil {
+			if err := s.tokenRepo.RevokeFamily(*stored.FamilyID); err != nil {
+				log.Printf("Error revoking token family %s: %v", *stored.FamilyID, err)
+			}
+		}
+		return nil, fmt.Errorf("%w: authorization code has already been used", ErrInvalidGrant)
+	}
+
+	user, err := s.GetUser(stored.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	opts := utils.TokenOptions{Scope: stored.Scope, ClientID: client.ID, AuthTime: stored.AuthTime, AMR: stored.AMR}
+	tokenPair, err := s.issueTokens(user, opts, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	// Remember the family so that a replayed code can revoke it
+	if err := s.tokenRepo.SetAuthorizationCodeFamily(codeHash, tokenPair.FamilyID); err != nil {
+		return nil, err
+	}
+
+	return s.oauthResult(user, tokenPair, opts, stored.Nonce)
+}
+
+// verifyCodeChallenge checks a PKCE verifier against an S256 challenge (RFC 7636 section 4.6)
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
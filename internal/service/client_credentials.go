@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+)
+
+// GrantTypeClientCredentials is the grant a client uses to get a token for itself
+const GrantTypeClientCredentials = "client_credentials"
+
+// ClientCredentialsGrant issues a short-lived machine token to a confidential client (RFC 6749 section 4.4).
+// The scope defaults to every scope the client is allowed; a requested audience must match the client's.
+func (s *AuthService) ClientCredentialsGrant(client *models.OAuthClient, scope, audience string) (*OAuthTokenResult, error) {
+	if client.Public {
+		return nil, ErrUnauthorizedClient
+	}
+
+	granted := client.AllowedScopes
+	if scope != "" {
+		granted = strings.Fields(scope)
+		for _, requested := range granted {
+			if !utils.HasScope(strings.Join(client.AllowedScopes, " "), requested) {
+				return nil, fmt.Errorf("%w: scope %q is not allowed for this client", ErrInvalidScope, requested)
+			}
+		}
+	}
+
+	if audience != "" && audience != client.Audience {
+		return nil, fmt.Errorf("%w: audience %q is not allowed for this client", ErrInvalidTarget, audience)
+	}
+
+	grantedScope := strings.Join(granted, " ")
+	token, expiresAt, err := utils.GenerateClientToken(client.ID, grantedScope, client.Audience)
+	if err != nil {
+		log.Printf("Error generating client token: %v", err)
+		return nil, err
+	}
+
+	// No refresh token: the client can always repeat the grant
+	return &OAuthTokenResult{
+		Tokens: utils.TokenPair{AccessToken: token, ExpiresAt: expiresAt.Unix()},
+		Scope:  grantedScope,
+	}, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/repository"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// clientSecretBytes is the entropy of generated client secrets
+const clientSecretBytes = 32
+
+// ClientService manages OAuth clients and authenticates them
+type ClientService struct {
+	clientRepo *repository.ClientRepository
+}
+
+// NewClientService creates a new ClientService instance
+func NewClientService(clientRepo *repository.ClientRepository) *ClientService {
+	return &ClientService{clientRepo: clientRepo}
+}
+
+// CreateClient registers a new client and returns it together with its plain text secret.
+// Public clients get no secret and must register at least one redirect URI.
+func (s *ClientService) CreateClient(req models.CreateClientRequest) (*models.OAuthClient, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: client name is required", ErrInvalidArgument)
+	}
+
+	for _, redirectURI := range req.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, "", err
+		}
+	}
+	if req.Public && len(req.RedirectURIs) == 0 {
+		return nil, "", fmt.Errorf("%w: public clients need at least one redirect URI", ErrInvalidArgument)
+	}
+
+	for _, scope := range req.AllowedScopes {
+		if !validScopeToken(scope) {
+			return nil, "", fmt.Errorf("%w: invalid scope %q", ErrInvalidArgument, scope)
+		}
+	}
+
+	client := &models.OAuthClient{
+		ID:            uuid.New().String(),
+		Name:          name,
+		RedirectURIs:  append([]string{}, req.RedirectURIs...),
+		Public:        req.Public,
+		AllowedScopes: append([]string{}, req.AllowedScopes...),
+		Audience:      strings.TrimSpace(req.Audience),
+	}
+
+	if req.Public {
+		if err := s.clientRepo.CreateClient(client); err != nil {
+			return nil, "", err
+		}
+		return client, "", nil
+	}
+
+	secret, err := utils.RandomToken(clientSecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Client secrets are hashed like passwords
+	secretHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing client secret: %v", err)
+		return nil, "", err
+	}
+
+	client.SecretHash = string(secretHash)
+	if err := s.clientRepo.CreateClient(client); err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+// AuthenticateClient verifies a client's ID and secret.
+// Public clients are identified by their ID alone and must not send a secret.
+func (s *ClientService) AuthenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.clientRepo.GetClient(clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, ErrInvalidClient
+		}
+		return client, nil
+	}
+
+	if secret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)); err != nil {
+		log.Printf("Invalid secret for client %s", clientID)
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// GetClient returns a client by ID
+func (s *ClientService) GetClient(clientID string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrClientNotFound
+	}
+
+	client, err := s.clientRepo.GetClient(clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrClientNotFound
+		}
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// ResolveRedirectURI checks a redirect URI against the client's allowlist.
+// When none is given and the client has exactly one registered, that one is used.
+func (s *ClientService) ResolveRedirectURI(client *models.OAuthClient, redirectURI string) (string, error) {
+	if redirectURI == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0], nil
+		}
+		return "", fmt.Errorf("%w: redirect_uri is required", ErrInvalidRedirectURI)
+	}
+
+	// Registered URIs are compared exactly, as required by RFC 6749 section 3.1.2
+	for _, registered := range client.RedirectURIs {
+		if registered == redirectURI {
+			return redirectURI, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s is not registered for this client", ErrInvalidRedirectURI, redirectURI)
+}
+
+// ListClients returns every registered client
+func (s *ClientService) ListClients() ([]models.OAuthClient, error) {
+	return s.clientRepo.ListClients()
+}
+
+// DeleteClient removes a client
+func (s *ClientService) DeleteClient(clientID string) error {
+	deleted, err := s.clientRepo.DeleteClient(clientID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrClientNotFound
+	}
+
+	return nil
+}
+
+// validateRedirectURI accepts absolute URIs without a fragment.
+// Plain http is only allowed for loopback addresses used during development.
+func validateRedirectURI(redirectURI string) error {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+		return fmt.Errorf("%w: invalid redirect URI %q", ErrInvalidArgument, redirectURI)
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		if parsed.Host == "" {
+			return fmt.Errorf("%w: invalid redirect URI %q", ErrInvalidArgument, redirectURI)
+		}
+	case "http":
+		host := parsed.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("%w: redirect URI %q must use https", ErrInvalidArgument, redirectURI)
+		}
+	}
+
+	return nil
+}
+
+// validScopeToken checks the scope-token syntax of RFC 6749 section 3.3
+func validScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, ch := range scope {
+		if ch < 0x21 || ch > 0x7e || ch == '"' || ch == '\\' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/diplom/auth-service/internal/mail"
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// VerifyEmail marks the address in a verification link as verified
+func (s *AuthService) VerifyEmail(token string) (*models.User, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: token is required", ErrInvalidArgument)
+	}
+
+	claims, err := utils.ParseEmailVerificationToken(token)
+	if err != nil {
+		log.Printf("Invalid email verification token: %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// The link only counts for the address it was sent to
+	verified, err := s.userRepo.SetEmailVerified(userID, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidToken
+	}
+
+	return s.GetUser(userID)
+}
+
+// ResendVerificationEmail sends a new verification link to an unverified address.
+// Unknown and already verified addresses are ignored, so the result does not reveal which accounts exist.
+func (s *AuthService) ResendVerificationEmail(email string) error {
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	s.sendVerificationEmail(user)
+	return nil
+}
+
+// sendVerificationEmail mails a verification link to the user.
+// Failures are only logged because the user can ask for another link.
+func (s *AuthService) sendVerificationEmail(user *models.User) {
+	token, err := utils.GenerateEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return
+	}
+
+	s.sendMail(user, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Open the link below to confirm your email address:\n\n%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, ignore this email.\n",
+			utils.EmailVerificationURL(token), int(utils.EmailVerificationTTL.Hours())),
+	})
+}
+
+// checkEmailVerified rejects users with an unverified address when verification is required
+func checkEmailVerified(user *models.User) error {
+	if utils.RequireEmailVerification() && !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
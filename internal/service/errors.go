@@ -0,0 +1,68 @@
+package service
+
+import "errors"
+
+var (
+	// ErrInvalidArgument is returned when the input fails validation
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrUserExists is returned when registering an email that is already taken
+	ErrUserExists = errors.New("user with this email already exists")
+	// ErrUserNotFound is returned when the requested user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredentials is returned when the email or password is wrong
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned when a token is malformed, expired or unknown
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenRevoked is returned when a token belongs to a revoked family
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrTokenReused is returned when an already rotated refresh token is presented again
+	ErrTokenReused = errors.New("refresh token has already been used")
+	// ErrInvalidClient is returned when OAuth client authentication fails
+	ErrInvalidClient = errors.New("invalid client credentials")
+	// ErrClientNotFound is returned when the requested OAuth client does not exist
+	ErrClientNotFound = errors.New("client not found")
+	// ErrInvalidGrant is returned when an OAuth grant is invalid, expired or issued to another client
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrInvalidScope is returned when an unknown scope is requested
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidRedirectURI is returned when a redirect URI is not registered for the client
+	ErrInvalidRedirectURI = errors.New("invalid redirect URI")
+	// ErrUnsupportedResponseType is returned for authorization requests other than response_type=code
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+	// ErrUnauthorizedClient is returned when a client may not use the requested grant
+	ErrUnauthorizedClient = errors.New("client is not allowed to use this grant")
+	// ErrInvalidTarget is returned when a token is requested for an audience the client may not use
+	ErrInvalidTarget = errors.New("invalid target")
+	// ErrMFAAlreadyEnabled is returned when enrolling a second factor that is already active
+	ErrMFAAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	// ErrMFANotEnrolled is returned when a second factor operation needs an enrollment that does not exist
+	ErrMFANotEnrolled = errors.New("multi-factor authentication is not enrolled")
+	// ErrInvalidMFACode is returned when a second factor code is wrong, expired or replayed
+	ErrInvalidMFACode = errors.New("invalid verification code")
+	// ErrMFARequired is returned by grants that cannot perform the second login step
+	ErrMFARequired = errors.New("multi-factor authentication required")
+	// ErrInsufficientScope is returned when a token lacks the scope required for an operation
+	ErrInsufficientScope = errors.New("insufficient scope")
+	// ErrPermissionDenied is returned when a token belongs to someone not allowed to access the resource
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInvalidWebAuthnResponse is returned when a WebAuthn registration or assertion fails verification
+	ErrInvalidWebAuthnResponse = errors.New("invalid WebAuthn response")
+	// ErrCredentialExists is returned when registering an authenticator that is already registered
+	ErrCredentialExists = errors.New("credential is already registered")
+	// ErrCredentialNotFound is returned when the requested WebAuthn credential does not exist
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrSessionNotFound is returned when the requested session does not exist or belongs to another user
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrEmailNotVerified is returned when an unverified user logs in while verification is required
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrRoleNotFound is returned when the requested role does not exist
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists is returned when defining a role whose name is taken
+	ErrRoleExists = errors.New("role already exists")
+	// ErrPermissionNotFound is returned when the requested permission does not exist
+	ErrPermissionNotFound = errors.New("permission not found")
+	// ErrPermissionExists is returned when defining a permission whose name is taken
+	ErrPermissionExists = errors.New("permission already exists")
+	// ErrTooManyAttempts is matched by LockoutError while logins are blocked after failed attempts
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+)
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/repository"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// LockoutError is returned while logins for an account or source address are blocked after failed attempts
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%v, retry in %d seconds", ErrTooManyAttempts, e.RetrySeconds())
+}
+
+// Unwrap makes errors.Is match ErrTooManyAttempts
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// RetrySeconds returns the wait in whole seconds, rounded up, for the Retry-After header
+func (e *LockoutError) RetrySeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// UnlockUser clears the failed logins of a user's account, lifting an active lock
+func (s *AuthService) UnlockUser(userID uuid.UUID, info RequestInfo) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.attemptRepo.Reset(repository.AttemptScopeAccount, accountKey(user.Email)); err != nil {
+		return err
+	}
+
+	log.Printf("Login lockout of user %s lifted", user.ID)
+	s.recordAudit(userAuditEvent(models.AuditUserUnlock, models.AuditSuccess, user), info)
+	return nil
+}
+
+// checkLoginAllowed returns a LockoutError while the account or the source address is blocked
+func (s *AuthService) checkLoginAllowed(email string, info RequestInfo) error {
+	wait, err := s.attemptRepo.LockedFor(accountKey(email), info.IP)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return &LockoutError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a wrong password against the account and the source address
+// and blocks further attempts according to the lockout policy
+func (s *AuthService) recordLoginFailure(email string, info RequestInfo) {
+	policy := utils.LoginLockoutPolicy()
+
+	// Unknown emails are counted too, so a lockout does not reveal whether an account exists
+	s.recordFailure(repository.AttemptScopeAccount, accountKey(email), policy, policy.AccountDelay)
+	if info.IP != "" {
+		s.recordFailure(repository.AttemptScopeIP, info.IP, policy, policy.IPDelay)
+	}
+}
+
+// recordFailure counts one failure for a key and locks it for the delay the policy gives
+func (s *AuthService) recordFailure(scope, key string, policy utils.LockoutPolicy, delay func(int) time.Duration) {
+	failures, err := s.attemptRepo.RecordFailure(scope, key, policy.Duration)
+	if err != nil {
+		return
+	}
+
+	if wait := delay(failures); wait > 0 {
+		if wait == policy.Duration {
+			log.Printf("Logins for %s %q locked for %s after %d failed attempts", scope, key, wait, failures)
+		}
+		s.attemptRepo.Lock(scope, key, wait)
+	}
+}
+
+// recordLoginSuccess forgets the failed logins of the account.
+// The source address keeps its count so that one valid account cannot reset it.
+func (s *AuthService) recordLoginSuccess(email string) {
+	s.attemptRepo.Reset(repository.AttemptScopeAccount, accountKey(email))
+}
+
+// accountKey returns the counter key of an email address
+func accountKey(email string) string {
+	return strings.ToLower(email)
+}
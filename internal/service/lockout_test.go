@@ -0,0 +1,312 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/passhash"
+	"github.com/diplom/auth-service/internal/repository"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeAttemptStore keeps login attempt counters in memory the way LoginAttemptRepository keeps them in Postgres
+type fakeAttemptStore struct {
+	failures    map[string]int
+	lockedUntil map[string]time.Time
+	// locks lists the duration of every Lock call per counter
+	locks map[string][]time.Duration
+}
+
+func newFakeAttemptStore() *fakeAttemptStore {
+	return &fakeAttemptStore{
+		failures:    make(map[string]int),
+		lockedUntil: make(map[string]time.Time),
+		locks:       make(map[string][]time.Duration),
+	}
+}
+
+func attemptID(scope, key string) string {
+	return scope + "/" + key
+}
+
+func (f *fakeAttemptStore) IsLocked(scope, key string) (bool, error) {
+	return time.Now().Before(f.lockedUntil[attemptID(scope, key)]), nil
+}
+
+func (f *fakeAttemptStore) Lock(scope, key string, duration time.Duration) error {
+	id := attemptID(scope, key)
+	f.lockedUntil[id] = time.Now().Add(duration)
+	f.locks[id] = append(f.locks[id], duration)
+	return nil
+}
+
+func (f *fakeAttemptStore) LockedFor(account, ip string) (time.Duration, error) {
+	wait := time.Until(f.lockedUntil[attemptID(repository.AttemptScopeAccount, account)])
+	if ip != "" {
+		if ipWait := time.Until(f.lockedUntil[attemptID(repository.AttemptScopeIP, ip)]); ipWait > wait {
+			wait = ipWait
+		}
+	}
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+func (f *fakeAttemptStore) RecordFailure(scope, key string, window time.Duration) (int, error) {
+	id := attemptID(scope, key)
+	f.failures[id]++
+	return f.failures[id], nil
+}
+
+func (f *fakeAttemptStore) Reset(scope, key string) error {
+	id := attemptID(scope, key)
+	delete(f.failures, id)
+	delete(f.lockedUntil, id)
+	return nil
+}
+
+// fakeUserStore serves a single user
+type fakeUserStore struct {
+	userStore
+	user *models.User
+}
+
+func (f *fakeUserStore) GetUserByEmail(email string) (*models.User, error) {
+	if f.user == nil || !strings.EqualFold(f.user.Email, email) {
+		return nil, sql.ErrNoRows
+	}
+	return f.user, nil
+}
+
+func (f *fakeUserStore) GetUserByID(id uuid.UUID) (*models.User, error) {
+	if f.user == nil || f.user.ID != id {
+		return nil, sql.ErrNoRows
+	}
+	return f.user, nil
+}
+
+func (f *fakeUserStore) RecordLogin(entry *models.LoginHistoryEntry) error {
+	return nil
+}
+
+// fakeMFAStore gives the user one unused recovery code
+type fakeMFAStore struct {
+	mfaStore
+	code models.RecoveryCode
+}
+
+func (f *fakeMFAStore) MFAMethods(userID uuid.UUID) ([]string, error) {
+	return []string{utils.AMROTP}, nil
+}
+
+func (f *fakeMFAStore) ListUnusedRecoveryCodes(userID uuid.UUID) ([]models.RecoveryCode, error) {
+	return []models.RecoveryCode{f.code}, nil
+}
+
+func (f *fakeMFAStore) UseRecoveryCode(id uuid.UUID) (bool, error) {
+	return id == f.code.ID, nil
+}
+
+type fakeAuditStore struct {
+	auditStore
+}
+
+func (f *fakeAuditStore) Record(event *models.AuditEvent) error {
+	return nil
+}
+
+type fakeRoleStore struct {
+	roleStore
+}
+
+func (f *fakeRoleStore) GetUserAccess(userID uuid.UUID) (*models.UserAccess, error) {
+	return &models.UserAccess{}, nil
+}
+
+type fakeTokenStore struct {
+	tokenStore
+}
+
+func (f *fakeTokenStore) CreateFamily(session *models.Session) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (f *fakeTokenStore) SaveRefreshToken(token *models.RefreshToken) error {
+	return nil
+}
+
+// newLockoutTestService returns a service whose only repository is the attempt store
+func newLockoutTestService(t *testing.T, threshold int, duration time.Duration) (*AuthService, *fakeAttemptStore) {
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", strconv.Itoa(threshold))
+	t.Setenv("LOGIN_LOCKOUT_IP_THRESHOLD", "100")
+	t.Setenv("LOGIN_LOCKOUT_DURATION", duration.String())
+
+	attempts := newFakeAttemptStore()
+	return &AuthService{attemptRepo: attempts}, attempts
+}
+
+func TestRecordLoginFailureBacksOff(t *testing.T) {
+	s, attempts := newLockoutTestService(t, 7, time.Minute)
+
+	for i := 0; i < 7; i++ {
+		s.recordLoginFailure("user@example.com", RequestInfo{})
+	}
+
+	// No delay for the first two failures, then doubling from a second, then the full lock
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, time.Minute}
+	got := attempts.locks[attemptID(repository.AttemptScopeAccount, "user@example.com")]
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("account locks = %v, want %v", got, want)
+	}
+}
+
+func TestCheckLoginAllowedAtThreshold(t *testing.T) {
+	s, attempts := newLockoutTestService(t, 5, time.Minute)
+	info := RequestInfo{IP: "192.0.2.1"}
+
+	for i := 0; i < 4; i++ {
+		s.recordLoginFailure("user@example.com", info)
+	}
+	// Lift the backoff so only the threshold decides
+	delete(attempts.lockedUntil, attemptID(repository.AttemptScopeAccount, "user@example.com"))
+	if err := s.checkLoginAllowed("user@example.com", info); err != nil {
+		t.Fatalf("checkLoginAllowed below the threshold: %v", err)
+	}
+
+	s.recordLoginFailure("user@example.com", info)
+
+	err := s.checkLoginAllowed("user@example.com", info)
+	var lockout *LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("checkLoginAllowed at the threshold = %v, want a LockoutError", err)
+	}
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("errors.Is(%v, ErrTooManyAttempts) = false", err)
+	}
+	if lockout.RetryAfter <= time.Minute-time.Second || lockout.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want about %s", lockout.RetryAfter, time.Minute)
+	}
+	if got := lockout.RetrySeconds(); got != 60 {
+		t.Errorf("RetrySeconds = %d, want 60", got)
+	}
+
+	// The address has not reached its own threshold, so other accounts behind it can still log in
+	if err := s.checkLoginAllowed("other@example.com", info); err != nil {
+		t.Errorf("checkLoginAllowed for another account: %v", err)
+	}
+}
+
+func TestCheckLoginAllowedLocksAddress(t *testing.T) {
+	s, _ := newLockoutTestService(t, 5, time.Minute)
+	t.Setenv("LOGIN_LOCKOUT_IP_THRESHOLD", "3")
+	info := RequestInfo{IP: "192.0.2.1"}
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		s.recordLoginFailure(email, info)
+	}
+
+	if err := s.checkLoginAllowed("d@example.com", info); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("checkLoginAllowed from a locked address = %v, want ErrTooManyAttempts", err)
+	}
+	if err := s.checkLoginAllowed("d@example.com", RequestInfo{IP: "192.0.2.2"}); err != nil {
+		t.Errorf("checkLoginAllowed from another address: %v", err)
+	}
+}
+
+func TestLockoutFoldsAccountCase(t *testing.T) {
+	s, attempts := newLockoutTestService(t, 3, time.Minute)
+
+	s.recordLoginFailure("Alice@Example.com", RequestInfo{})
+	s.recordLoginFailure("alice@example.com", RequestInfo{})
+	s.recordLoginFailure("ALICE@EXAMPLE.COM", RequestInfo{})
+
+	if got := attempts.failures[attemptID(repository.AttemptScopeAccount, "alice@example.com")]; got != 3 {
+		t.Errorf("failures of alice@example.com = %d, want 3", got)
+	}
+	if err := s.checkLoginAllowed("aLiCe@example.com", RequestInfo{}); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("checkLoginAllowed with another case = %v, want ErrTooManyAttempts", err)
+	}
+
+	s.recordLoginSuccess("Alice@EXAMPLE.com")
+	if err := s.checkLoginAllowed("alice@example.com", RequestInfo{}); err != nil {
+		t.Errorf("checkLoginAllowed after a success with another case: %v", err)
+	}
+}
+
+func TestLoginResetsFailuresOnlyAfterMFA(t *testing.T) {
+	t.Setenv("JWT_SECRET", "lockout test secret")
+	s, attempts := newLockoutTestService(t, 5, time.Minute)
+	account := attemptID(repository.AttemptScopeAccount, "user@example.com")
+	info := RequestInfo{IP: "192.0.2.1"}
+
+	s.hasher = passhash.NewHasher(&passhash.Bcrypt{Cost: bcrypt.MinCost})
+	passwordHash, err := s.hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: uuid.New(), Email: "user@example.com", EmailVerified: true, PasswordHash: passwordHash, Role: "user"}
+
+	code, err := utils.GenerateRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, _ := utils.NormalizeRecoveryCode(code)
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(normalized), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.userRepo = &fakeUserStore{user: user}
+	s.mfaRepo = &fakeMFAStore{code: models.RecoveryCode{ID: uuid.New(), UserID: user.ID, CodeHash: string(codeHash)}}
+	s.auditRepo = &fakeAuditStore{}
+	s.roleRepo = &fakeRoleStore{}
+	s.tokenRepo = &fakeTokenStore{}
+
+	if _, err := s.Login("user@example.com", "wrong horse", info); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password = %v, want ErrInvalidCredentials", err)
+	}
+
+	// The password alone must not clear the failures, or it would reset the lockout of the second factor
+	result, err := s.Login("user@example.com", "correct horse", info)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if result.MFA == nil {
+		t.Fatal("Login returned no MFA challenge")
+	}
+	if got := attempts.failures[account]; got != 1 {
+		t.Errorf("failures after the password = %d, want 1", got)
+	}
+
+	other, err := utils.GenerateRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteMFALogin(result.MFA.Token, other, info); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("CompleteMFALogin with a wrong code = %v, want ErrInvalidMFACode", err)
+	}
+	if got := attempts.failures[account]; got != 2 {
+		t.Errorf("failures after a wrong code = %d, want 2", got)
+	}
+
+	if _, err := s.CompleteMFALogin(result.MFA.Token, code, info); err != nil {
+		t.Fatalf("CompleteMFALogin: %v", err)
+	}
+	if got, ok := attempts.failures[account]; ok {
+		t.Errorf("failures after the second factor = %d, want the counter reset", got)
+	}
+
+	// The address keeps its count so that one valid account cannot reset it
+	if got := attempts.failures[attemptID(repository.AttemptScopeIP, info.IP)]; got != 2 {
+		t.Errorf("failures of the address = %d, want 2", got)
+	}
+}
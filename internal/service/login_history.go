@@ -0,0 +1,49 @@
+package service
+
+import (
+	"log"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLoginHistoryLimit = 20
+	maxLoginHistoryLimit     = 100
+)
+
+// LoginHistory returns the most recent successful and failed logins of a user, newest first
+func (s *AuthService) LoginHistory(userID uuid.UUID, limit int) ([]models.LoginHistoryEntry, error) {
+	if limit <= 0 {
+		limit = defaultLoginHistoryLimit
+	}
+	if limit > maxLoginHistoryLimit {
+		limit = maxLoginHistoryLimit
+	}
+
+	return s.userRepo.ListLoginHistory(userID, limit)
+}
+
+// recordLogin adds a login of a known user to the login history and the audit log.
+// Failures to write are only logged and never fail the login.
+func (s *AuthService) recordLogin(user *models.User, entry models.LoginHistoryEntry, info RequestInfo) {
+	entry.UserID = user.ID
+	entry.DeviceName = utils.DeviceName(info.DeviceName, info.UserAgent)
+	entry.UserAgent = info.UserAgent
+	entry.IP = info.IP
+	if err := s.userRepo.RecordLogin(&entry); err != nil {
+		log.Printf("Error recording login of user %s: %v", user.ID, err)
+	}
+
+	// Successful logins are audited with the factors used
+	event := userAuditEvent(models.AuditLogin, models.AuditFailure, user)
+	event.ClientID = entry.ClientID
+	event.Reason = entry.Reason
+	if entry.Success {
+		event.Outcome = models.AuditSuccess
+		event.Reason = strings.Join(entry.Methods, " ")
+	}
+	s.recordAudit(event, info)
+}
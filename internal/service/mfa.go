@@ -0,0 +1,292 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/repository"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// Second factors that can be listed in an MFA challenge, next to MFAMethodRecoveryCode
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// MFAChallenge is returned instead of tokens when the user must complete a second login step
+type MFAChallenge struct {
+	Token     string
+	ExpiresAt int64
+	Methods   []string
+}
+
+// EnrollTOTP generates a new TOTP secret for the user.
+// The secret only becomes active once ConfirmTOTP accepts a code generated from it.
+func (s *AuthService) EnrollTOTP(userID uuid.UUID) (*models.TOTPEnrollResponse, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// TOTP secrets are stored encrypted like signing keys
+	encrypted, err := utils.EncryptSecret([]byte(secret))
+	if err != nil {
+		log.Printf("Error encrypting TOTP secret: %v", err)
+		return nil, err
+	}
+
+	saved, err := s.mfaRepo.SaveTOTPSecret(user.ID, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return &models.TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(secret, user.Email),
+	}, nil
+}
+
+// ConfirmTOTP activates a pending TOTP enrollment with the first code from the authenticator app.
+// It returns a new set of recovery codes if the user had none.
+func (s *AuthService) ConfirmTOTP(userID uuid.UUID, code string) ([]string, error) {
+	credential, err := s.totpCredential(userID)
+	if err != nil {
+		return nil, err
+	}
+	if credential.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(credential, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.EnableTOTP(userID); err != nil {
+		return nil, err
+	}
+
+	return s.ensureRecoveryCodes(userID)
+}
+
+// DisableTOTP removes the user's TOTP enrollment after checking a current code
+func (s *AuthService) DisableTOTP(userID uuid.UUID, code string) error {
+	credential, err := s.totpCredential(userID)
+	if err != nil {
+		return err
+	}
+	if credential.EnabledAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	if err := s.verifyTOTP(credential, code); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteTOTP(userID); err != nil {
+		return err
+	}
+
+	return s.dropRecoveryCodesWithoutMFA(userID)
+}
+
+// CompleteMFALogin finishes a login started by Login with the second factor code or a recovery code
+func (s *AuthService) CompleteMFALogin(mfaToken, code string, info RequestInfo) (*AuthResult, error) {
+	user, amr, err := s.verifySecondFactor(mfaToken, code, info)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := s.issueTokens(user, utils.TokenOptions{AMR: amr}, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	return &AuthResult{User: user, Tokens: tokenPair}, nil
+}
+
+// mfaChallenge returns a challenge if the user has a second factor enabled, or nil otherwise.
+// amr lists the factors the user has already presented.
+func (s *AuthService) mfaChallenge(user *models.User, amr []string) (*MFAChallenge, error) {
+	methods, err := s.mfaRepo.MFAMethods(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	token, expiresAt, err := utils.GenerateMFAToken(user.ID, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallenge{Token: token, ExpiresAt: expiresAt.Unix(), Methods: methods}, nil
+}
+
+// verifySecondFactor checks an MFA challenge token and a TOTP or recovery code.
+// It returns the user and the factors used for the whole login.
+// Wrong codes count against the challenge as well as the account and source address lockouts,
+// so one password cannot be turned into unlimited guesses at the second factor.
+func (s *AuthService) verifySecondFactor(mfaToken, code string, info RequestInfo) (*models.User, []string, error) {
+	if code == "" {
+		return nil, nil, ErrInvalidArgument
+	}
+
+	user, claims, err := s.mfaTokenUser(mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A challenge that ran out of attempts is dead; the user has to enter the password again
+	exhausted, err := s.attemptRepo.IsLocked(repository.AttemptScopeMFAToken, claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exhausted {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if err := s.checkLoginAllowed(user.Email, info); err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			s.recordLogin(user, models.LoginHistoryEntry{Reason: auditReasonLocked}, info)
+		}
+		return nil, nil, err
+	}
+
+	amr, err := s.checkSecondFactorCode(user, claims, code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordMFAFailure(user, claims, info)
+		}
+		return nil, nil, err
+	}
+
+	return user, amr, nil
+}
+
+// recordMFAFailure counts a wrong second factor code against the challenge, the account and the source address
+func (s *AuthService) recordMFAFailure(user *models.User, claims *utils.TokenClaims, info RequestInfo) {
+	s.recordLoginFailure(user.Email, info)
+	s.recordLogin(user, models.LoginHistoryEntry{Reason: auditReasonInvalidMFACode}, info)
+
+	failures, err := s.attemptRepo.RecordFailure(repository.AttemptScopeMFAToken, claims.ID, utils.MFATokenTTL)
+	if err != nil {
+		return
+	}
+	if failures >= utils.MFATokenMaxAttempts {
+		log.Printf("MFA challenge %s of user %s retired after %d wrong codes", claims.ID, user.ID, failures)
+		s.attemptRepo.Lock(repository.AttemptScopeMFAToken, claims.ID, utils.MFATokenTTL)
+	}
+}
+
+// checkSecondFactorCode checks a TOTP or recovery code of the user and returns the factors used for the whole login
+func (s *AuthService) checkSecondFactorCode(user *models.User, claims *utils.TokenClaims, code string) ([]string, error) {
+	// Recovery codes are longer than one-time passwords, so the shape of the code tells them apart
+	if _, ok := utils.NormalizeRecoveryCode(code); ok {
+		if err := s.useRecoveryCode(user.ID, code); err != nil {
+			return nil, err
+		}
+		return append(append([]string{}, claims.AMR...), utils.AMRRecovery, utils.AMRMFA), nil
+	}
+
+	credential, err := s.totpCredential(user.ID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			return nil, ErrInvalidMFACode
+		}
+		return nil, err
+	}
+	if credential.EnabledAt == nil {
+		return nil, ErrInvalidMFACode
+	}
+
+	if err := s.verifyTOTP(credential, code); err != nil {
+		return nil, err
+	}
+
+	return append(append([]string{}, claims.AMR...), utils.AMROTP, utils.AMRMFA), nil
+}
+
+// mfaTokenUser checks an MFA challenge token and loads the user it was issued to
+func (s *AuthService) mfaTokenUser(mfaToken string) (*models.User, *utils.TokenClaims, error) {
+	if mfaToken == "" {
+		return nil, nil, ErrInvalidArgument
+	}
+
+	claims, err := utils.ParseMFAToken(mfaToken)
+	if err != nil {
+		log.Printf("Invalid MFA token: %v", err)
+		return nil, nil, ErrInvalidToken
+	}
+
+	// Failed attempts are counted per challenge, so every challenge needs an ID
+	if claims.ID == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, nil, ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	return user, claims, nil
+}
+
+// totpCredential loads the user's TOTP enrollment
+func (s *AuthService) totpCredential(userID uuid.UUID) (*models.TOTPCredential, error) {
+	credential, err := s.mfaRepo.GetTOTP(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// verifyTOTP checks a code against the enrollment and burns its time period so it cannot be replayed
+func (s *AuthService) verifyTOTP(credential *models.TOTPCredential, code string) error {
+	secret, err := utils.DecryptSecret(credential.Secret)
+	if err != nil {
+		log.Printf("Error decrypting TOTP secret: %v", err)
+		return err
+	}
+
+	counter, ok := utils.ValidateTOTP(string(secret), code, time.Now(), credential.LastCounter)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	used, err := s.mfaRepo.UseTOTPCounter(credential.UserID, counter)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// Grant types accepted by the token endpoint
+const (
+	GrantTypePassword     = "password"
+	GrantTypeRefreshToken = "refresh_token"
+)
+
+// OAuthTokenResult is the outcome of a successful token endpoint grant
+type OAuthTokenResult struct {
+	Tokens  utils.TokenPair
+	IDToken string
+	Scope   string
+}
+
+// PasswordGrant exchanges a user's email and password for tokens issued to the client.
+// An ID token is included when the openid scope is requested.
+func (s *AuthService) PasswordGrant(client *models.OAuthClient, email, password, scope, nonce string, info RequestInfo) (*OAuthTokenResult, error) {
+	// Public clients must not handle user passwords
+	if client.Public {
+		return nil, ErrUnauthorizedClient
+	}
+
+	scope, err := normalizeScope(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.authenticateUser(email, password, info)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrInvalidArgument) || errors.Is(err, ErrEmailNotVerified) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGrant, err)
+		}
+		return nil, err
+	}
+
+	// The password grant has no way to ask for a second factor
+	amr := []string{utils.AMRPassword}
+	challenge, err := s.mfaChallenge(user, amr)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGrant, ErrMFARequired)
+	}
+
+	opts := utils.TokenOptions{Scope: scope, ClientID: client.ID, AuthTime: time.Now(), AMR: amr}
+	tokenPair, err := s.issueTokens(user, opts, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	return s.oauthResult(user, tokenPair, opts, nonce)
+}
+
+// RefreshTokenGrant rotates a refresh token issued to the client.
+// A narrower scope may be requested; the original authentication time is kept.
+func (s *AuthService) RefreshTokenGrant(client *models.OAuthClient, refreshToken, scope string, info RequestInfo) (*OAuthTokenResult, error) {
+	if refreshToken == "" {
+		return nil, fmt.Errorf("%w: refresh token is required", ErrInvalidArgument)
+	}
+
+	claims, err := utils.ParseRefreshToken(refreshToken)
+	if err != nil {
+		log.Printf("Invalid refresh token: %v", err)
+		return nil, ErrInvalidGrant
+	}
+
+	// The token must have been issued to the authenticated client
+	if claims.ClientID != client.ID {
+		log.Printf("Client %s presented a refresh token issued to %q", client.ID, claims.ClientID)
+		return nil, ErrInvalidGrant
+	}
+
+	if scope != "" {
+		scope, err = normalizeScope(scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, requested := range strings.Fields(scope) {
+			if !utils.HasScope(claims.Scope, requested) {
+				return nil, fmt.Errorf("%w: scope %q was not granted", ErrInvalidScope, requested)
+			}
+		}
+		claims.Scope = scope
+	}
+
+	result, err := s.rotateRefreshToken(claims, info)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrTokenReused) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidGrant, err)
+		}
+		return nil, err
+	}
+
+	return s.oauthResult(result.User, result.Tokens, claims.Options(), "")
+}
+
+// UserInfo returns the claims about the token's user allowed by its scopes
+func (s *AuthService) UserInfo(claims *utils.TokenClaims) (*models.UserInfoResponse, error) {
+	if !utils.HasScope(claims.Scope, utils.ScopeOpenID) {
+		return nil, ErrInsufficientScope
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Read the current profile rather than the values frozen in the token
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UserInfoResponse{Sub: user.ID.String()}
+	if utils.HasScope(claims.Scope, utils.ScopeEmail) {
+		response.Email = user.Email
+		response.EmailVerified = &user.EmailVerified
+	}
+	if utils.HasScope(claims.Scope, utils.ScopeProfile) {
+		response.PreferredUsername = user.Email
+		response.Name = user.Name
+		response.Role = user.Role
+	}
+
+	return response, nil
+}
+
+// oauthResult adds an ID token to the issued pair when the openid scope was granted
+func (s *AuthService) oauthResult(user *models.User, tokenPair utils.TokenPair, opts utils.TokenOptions, nonce string) (*OAuthTokenResult, error) {
+	result := &OAuthTokenResult{Tokens: tokenPair, Scope: opts.Scope}
+
+	if utils.HasScope(opts.Scope, utils.ScopeOpenID) {
+		idToken, err := utils.GenerateIDToken(user.ID, user.Email, nonce, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.IDToken = idToken
+	}
+
+	return result, nil
+}
+
+// normalizeScope checks that every requested scope is supported and removes duplicates
+func normalizeScope(scope string) (string, error) {
+	scopes := []string{}
+	for _, requested := range strings.Fields(scope) {
+		if !utils.HasScope(strings.Join(utils.SupportedScopes, " "), requested) {
+			return "", fmt.Errorf("%w: unsupported scope %q", ErrInvalidScope, requested)
+		}
+		if !utils.HasScope(strings.Join(scopes, " "), requested) {
+			scopes = append(scopes, requested)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
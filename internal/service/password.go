@@ -0,0 +1,287 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/diplom/auth-service/internal/mail"
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/passpolicy"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// passwordResetTTL is how long a password reset link stays valid
+const passwordResetTTL = time.Hour
+
+// passwordResetTokenBytes is the entropy of a password reset token
+const passwordResetTokenBytes = 32
+
+// ForgotPassword mails a single-use password reset link to the owner of the address.
+// Unknown addresses are ignored, so the result does not reveal which accounts exist.
+func (s *AuthService) ForgotPassword(email string) error {
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := utils.RandomToken(passwordResetTokenBytes)
+	if err != nil {
+		return err
+	}
+
+	// Only a hash of the token is stored, like authorization codes it is a bearer credential
+	if err := s.tokenRepo.SavePasswordResetToken(user.ID, utils.HashToken(token), time.Now().Add(passwordResetTTL)); err != nil {
+		return err
+	}
+
+	// Mail is delivered in the background so that the response time does not reveal the account either
+	go s.sendMail(user, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open the link below to choose a new password:\n\n%s\n\n"+
+			"The link expires in %d minutes and can be used once. If you did not ask to reset your password, ignore this email.\n",
+			utils.PasswordResetURL(token), int(passwordResetTTL.Minutes())),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out everywhere
+func (s *AuthService) ResetPassword(token, newPassword string, info RequestInfo) error {
+	if token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidArgument)
+	}
+	tokenHash := utils.HashToken(token)
+
+	// The policy needs the user's email, and a rejected password must leave the link usable
+	userID, err := s.tokenRepo.GetPasswordResetTokenUser(tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	if err := s.validatePassword(newPassword, user.Email); err != nil {
+		return err
+	}
+
+	// Consuming the token before the update makes it single-use even under concurrent requests
+	if _, err := s.tokenRepo.UsePasswordResetToken(tokenHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must lose every session it opened
+	if err := s.tokenRepo.RevokeUserFamilies(user.ID, uuid.Nil); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeletePasswordResetTokens(user.ID); err != nil {
+		return err
+	}
+
+	// The reset link reached the user's inbox, which proves they own the address
+	if !user.EmailVerified {
+		if _, err := s.userRepo.SetEmailVerified(user.ID, user.Email); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Password of user %s was reset", user.ID)
+	s.recordAudit(userAuditEvent(models.AuditPasswordReset, models.AuditSuccess, user), info)
+	s.sendMail(user, mail.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: "The password of your account was just reset and all sessions were signed out.\n\n" +
+			"If you did not do this, reset your password again right away and check your account's security settings.\n",
+	})
+
+	return nil
+}
+
+// sendMail delivers a message to the user and only logs failures
+func (s *AuthService) sendMail(user *models.User, msg mail.Message) {
+	if err := s.mailer.Send(msg); err != nil {
+		log.Printf("Error sending %q email to user %s: %v", msg.Subject, user.ID, err)
+	}
+}
+
+// ChangePassword replaces the password of a signed-in user after checking the current one.
+// With signOutOthers every session except the one of the access token is signed out.
+func (s *AuthService) ChangePassword(claims *utils.TokenClaims, currentPassword, newPassword string, signOutOthers bool, info RequestInfo) error {
+	if currentPassword == "" {
+		return fmt.Errorf("%w: current password is required", ErrInvalidArgument)
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkCurrentPassword(user, currentPassword, info); err != nil {
+		event := userAuditEvent(models.AuditPasswordChange, models.AuditFailure, user)
+		switch {
+		case errors.Is(err, ErrTooManyAttempts):
+			event.Reason = auditReasonLocked
+		case errors.Is(err, ErrInvalidCredentials):
+			event.Reason = auditReasonInvalidPassword
+		default:
+			return err
+		}
+		s.recordAudit(event, info)
+		return err
+	}
+
+	if err := s.validatePassword(newPassword, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	if signOutOthers {
+		// Tokens without a family (none issued by this service) keep nothing alive
+		currentFamily, _ := uuid.Parse(claims.FamilyID)
+		if err := s.tokenRepo.RevokeUserFamilies(user.ID, currentFamily); err != nil {
+			return err
+		}
+	}
+
+	// A pending reset link would undo the change
+	if err := s.tokenRepo.DeletePasswordResetTokens(user.ID); err != nil {
+		return err
+	}
+
+	log.Printf("Password of user %s was changed", user.ID)
+	s.recordAudit(userAuditEvent(models.AuditPasswordChange, models.AuditSuccess, user), info)
+	s.sendMail(user, mail.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: "The password of your account was just changed.\n\n" +
+			"If you did not do this, reset your password right away using the \"Forgot password\" link.\n",
+	})
+
+	return nil
+}
+
+// PasswordPolicyError lists every password policy rule a new password breaks
+type PasswordPolicyError struct {
+	Violations []passpolicy.Violation
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidArgument, strings.Join(messages, "; "))
+}
+
+// Unwrap makes errors.Is match ErrInvalidArgument
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrInvalidArgument
+}
+
+// validatePassword checks a new password of the account with the given email against the password policy
+func (s *AuthService) validatePassword(password, email string) error {
+	if violations := s.passwordPolicy.Check(password, email); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// hashPassword returns the hash of a password for storage, made with the configured algorithm
+func (s *AuthService) hashPassword(password string) (string, error) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		return "", err
+	}
+	return hashedPassword, nil
+}
+
+// checkPassword compares a password with the user's stored hash.
+// rehash reports that the hash was made with an older algorithm or weaker parameters.
+func (s *AuthService) checkPassword(user *models.User, password string) (rehash bool, err error) {
+	rehash, err = s.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		log.Printf("Invalid password: %v", err)
+		return false, ErrInvalidCredentials
+	}
+	return rehash, nil
+}
+
+// checkCurrentPassword confirms the password of a signed-in user. Wrong passwords count toward
+// the login lockout, so a stolen access token cannot be used to guess the password either.
+func (s *AuthService) checkCurrentPassword(user *models.User, password string, info RequestInfo) error {
+	if err := s.checkLoginAllowed(user.Email, info); err != nil {
+		return err
+	}
+
+	if _, err := s.checkPassword(user, password); err != nil {
+		s.recordLoginFailure(user.Email, info)
+		return err
+	}
+	return nil
+}
+
+// upgradePasswordHash stores a new hash of a password that has just been verified.
+// Failures are only logged because the old hash keeps working.
+func (s *AuthService) upgradePasswordHash(user *models.User, password string) {
+	hashedPassword, err := s.hashPassword(password)
+	if err != nil {
+		return
+	}
+
+	// A password changed since the user was loaded must not be overwritten
+	upgraded, err := s.userRepo.ReplacePasswordHash(user.ID, user.PasswordHash, hashedPassword)
+	if err != nil || !upgraded {
+		return
+	}
+
+	log.Printf("Upgraded password hash of user %s to %s", user.ID, s.hasher.Algorithm())
+	user.PasswordHash = hashedPassword
+}
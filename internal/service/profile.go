@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diplom/auth-service/internal/mail"
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// maxNameLength is the longest display name accepted in a profile
+const maxNameLength = 255
+
+// ProfileUpdate lists the profile fields to change; nil fields are left as they are
+type ProfileUpdate struct {
+	Name            *string
+	Email           *string
+	CurrentPassword string
+}
+
+// UpdateProfile changes the editable fields of a user's profile and returns the updated user.
+// A new email address must be verified again, and the old address is told about the change.
+func (s *AuthService) UpdateProfile(userID uuid.UUID, update ProfileUpdate, info RequestInfo) (*models.User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if utf8.RuneCountInString(name) > maxNameLength {
+			return nil, fmt.Errorf("%w: name must be at most %d characters", ErrInvalidArgument, maxNameLength)
+		}
+		if name != user.Name {
+			if err := s.userRepo.UpdateName(user.ID, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if update.Email != nil && *update.Email != user.Email {
+		if err := s.changeEmail(user, *update.Email, update.CurrentPassword, info); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetUser(userID)
+}
+
+// changeEmail moves the account to a new address after checking the current password
+func (s *AuthService) changeEmail(user *models.User, email, currentPassword string, info RequestInfo) error {
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+
+	// A stolen access token alone must not be enough to take over the account
+	if currentPassword == "" {
+		return fmt.Errorf("%w: current password is required to change the email", ErrInvalidArgument)
+	}
+	if err := s.checkCurrentPassword(user, currentPassword, info); err != nil {
+		return err
+	}
+
+	exists, err := s.userRepo.UserExists(email)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrUserExists
+	}
+
+	if err := s.userRepo.UpdateEmail(user.ID, email); err != nil {
+		return err
+	}
+
+	s.sendMail(user, mail.Message{
+		To:      user.Email,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("The email address of your account was changed to %s.\n\n"+
+			"If you did not do this, your account may be compromised, contact the service administrator.\n", email),
+	})
+
+	changed := *user
+	changed.Email = email
+	changed.EmailVerified = false
+	s.sendVerificationEmail(&changed)
+
+	return nil
+}
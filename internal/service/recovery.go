@@ -0,0 +1,167 @@
+package service
+
+import (
+	"log"
+
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MFAMethodRecoveryCode is listed in an MFA challenge while the user has unused recovery codes
+const MFAMethodRecoveryCode = "recovery_code"
+
+// recoveryCodeCount is the number of codes in a set
+const recoveryCodeCount = 10
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with a new set.
+// The access token must come from a login that passed a second factor, so a stolen
+// password alone cannot be turned into a way around MFA.
+func (s *AuthService) RegenerateRecoveryCodes(claims *utils.TokenClaims) ([]string, error) {
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !containsString(claims.AMR, utils.AMRMFA) {
+		return nil, ErrMFARequired
+	}
+
+	enabled, err := s.hasSecondFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrMFANotEnrolled
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// RecoveryCodesRemaining returns the number of unused recovery codes
+func (s *AuthService) RecoveryCodesRemaining(userID uuid.UUID) (int, error) {
+	codes, err := s.mfaRepo.ListUnusedRecoveryCodes(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(codes), nil
+}
+
+// ensureRecoveryCodes issues recovery codes when the user enables a second factor without having any left.
+// It returns nil if the user still has unused codes.
+func (s *AuthService) ensureRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	remaining, err := s.RecoveryCodesRemaining(userID)
+	if err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, nil
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// issueRecoveryCodes generates a new set of codes and stores their bcrypt hashes, invalidating the old set
+func (s *AuthService) issueRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		// Hash the code the same way passwords are hashed
+		normalized, _ := utils.NormalizeRecoveryCode(code)
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalized), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("Error hashing recovery code: %v", err)
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, string(hash))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCode checks a recovery code against the user's unused codes and burns the one that matches.
+// Every guess costs a bcrypt comparison per unused code, so it must only be reached through
+// verifySecondFactor, which enforces the per-challenge attempt cap and the login lockout.
+func (s *AuthService) useRecoveryCode(userID uuid.UUID, code string) error {
+	normalized, ok := utils.NormalizeRecoveryCode(code)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	codes, err := s.mfaRepo.ListUnusedRecoveryCodes(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, stored := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(stored.CodeHash), []byte(normalized)) != nil {
+			continue
+		}
+
+		// Losing the race means the code was used concurrently
+		used, err := s.mfaRepo.UseRecoveryCode(stored.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+
+		log.Printf("Recovery code used by user %s, %d remaining", userID, len(codes)-1)
+		return nil
+	}
+
+	return ErrInvalidMFACode
+}
+
+// hasSecondFactor reports whether the user has a TOTP app or security key enabled
+func (s *AuthService) hasSecondFactor(userID uuid.UUID) (bool, error) {
+	methods, err := s.mfaRepo.MFAMethods(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, method := range methods {
+		if method != MFAMethodRecoveryCode {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// dropRecoveryCodesWithoutMFA removes the recovery codes once the user's last second factor is gone,
+// so that the codes do not keep asking for a second login step on their own
+func (s *AuthService) dropRecoveryCodesWithoutMFA(userID uuid.UUID) error {
+	enabled, err := s.hasSecondFactor(userID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return nil
+	}
+
+	return s.mfaRepo.DeleteRecoveryCodes(userID)
+}
+
+// containsString reports whether the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// Roles every installation has: new users get roleUser, and roleAdmin opens the administrative API
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+)
+
+var (
+	// roleNamePattern allows names such as "support" or "billing-admin"
+	roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,49}$`)
+	// permissionNamePattern allows names such as "reports:read" or "orders.export"
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.:*-]{0,99}$`)
+)
+
+// ListRoles returns every role with its permissions
+func (s *AuthService) ListRoles() ([]models.Role, error) {
+	return s.roleRepo.ListRoles()
+}
+
+// CreateRole defines a new role granting existing permissions
+func (s *AuthService) CreateRole(req models.CreateRoleRequest, info RequestInfo) (*models.Role, error) {
+	name := strings.TrimSpace(req.Name)
+	if !roleNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid role name %q", ErrInvalidArgument, name)
+	}
+
+	permissions, err := s.knownPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{Name: name, Description: strings.TrimSpace(req.Description), Permissions: permissions}
+	created, err := s.roleRepo.CreateRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrRoleExists
+	}
+
+	s.recordRoleChange(fmt.Sprintf("created role %s", name), info)
+	return role, nil
+}
+
+// UpdateRole replaces the description and the permissions of a role
+func (s *AuthService) UpdateRole(name string, req models.UpdateRoleRequest, info RequestInfo) (*models.Role, error) {
+	permissions, err := s.knownPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{Name: name, Description: strings.TrimSpace(req.Description), Permissions: permissions}
+	updated, err := s.roleRepo.UpdateRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrRoleNotFound
+	}
+
+	s.recordRoleChange(fmt.Sprintf("updated role %s with permissions [%s]", name, strings.Join(permissions, " ")), info)
+	return s.roleRepo.GetRole(name)
+}
+
+// DeleteRole removes a role from the system and from every user holding it.
+// The built-in roles cannot be deleted.
+func (s *AuthService) DeleteRole(name string, info RequestInfo) error {
+	if name == roleUser || name == roleAdmin {
+		return fmt.Errorf("%w: built-in role %q cannot be deleted", ErrInvalidArgument, name)
+	}
+
+	deleted, err := s.roleRepo.DeleteRole(name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRoleNotFound
+	}
+
+	s.recordRoleChange(fmt.Sprintf("deleted role %s", name), info)
+	return nil
+}
+
+// ListPermissions returns every permission
+func (s *AuthService) ListPermissions() ([]models.Permission, error) {
+	return s.roleRepo.ListPermissions()
+}
+
+// CreatePermission defines a new permission that roles can grant
+func (s *AuthService) CreatePermission(req models.CreatePermissionRequest, info RequestInfo) (*models.Permission, error) {
+	name := strings.TrimSpace(req.Name)
+	if !permissionNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid permission name %q", ErrInvalidArgument, name)
+	}
+
+	permission := &models.Permission{Name: name, Description: strings.TrimSpace(req.Description)}
+	created, err := s.roleRepo.CreatePermission(permission)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrPermissionExists
+	}
+
+	s.recordRoleChange(fmt.Sprintf("created permission %s", name), info)
+	return permission, nil
+}
+
+// DeletePermission removes a permission from the system and from every role granting it
+func (s *AuthService) DeletePermission(name string, info RequestInfo) error {
+	deleted, err := s.roleRepo.DeletePermission(name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPermissionNotFound
+	}
+
+	s.recordRoleChange(fmt.Sprintf("deleted permission %s", name), info)
+	return nil
+}
+
+// GetUserAccess returns the roles of a user and the permissions they grant
+func (s *AuthService) GetUserAccess(userID uuid.UUID) (*models.UserAccess, error) {
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.GetUserAccess(userID)
+}
+
+// SetUserRoles replaces the roles of a user.
+// The new roles show up in the user's tokens on the next login or refresh.
+func (s *AuthService) SetUserRoles(userID uuid.UUID, roles []string, info RequestInfo) (*models.UserAccess, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err = s.knownRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.roleRepo.GetUserAccess(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Administrators cannot lock themselves out of the administrative API
+	if info.ActorID == userID.String() && slices.Contains(before.Roles, roleAdmin) && !slices.Contains(roles, roleAdmin) {
+		return nil, fmt.Errorf("%w: you cannot remove your own %s role", ErrInvalidArgument, roleAdmin)
+	}
+
+	if err := s.roleRepo.SetUserRoles(userID, roles); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Roles of user %s changed from %v to %v", userID, before.Roles, roles)
+	event := userAuditEvent(models.AuditRoleChange, models.AuditSuccess, user)
+	event.Reason = roleChangeReason(before.Roles, roles)
+	s.recordAudit(event, info)
+
+	return s.roleRepo.GetUserAccess(userID)
+}
+
+// recordRoleChange audits a change to the definition of roles or permissions
+func (s *AuthService) recordRoleChange(reason string, info RequestInfo) {
+	s.recordAudit(models.AuditEvent{Event: models.AuditRoleChange, Outcome: models.AuditSuccess, Reason: reason}, info)
+}
+
+// roleChangeReason describes which roles a user gained and lost
+func roleChangeReason(before, after []string) string {
+	var changes []string
+	for _, role := range after {
+		if !slices.Contains(before, role) {
+			changes = append(changes, "+"+role)
+		}
+	}
+	for _, role := range before {
+		if !slices.Contains(after, role) {
+			changes = append(changes, "-"+role)
+		}
+	}
+	return strings.Join(changes, " ")
+}
+
+// knownRoles deduplicates and sorts role names and checks that every role exists
+func (s *AuthService) knownRoles(names []string) ([]string, error) {
+	roles, err := s.roleRepo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make([]string, 0, len(roles))
+	for _, role := range roles {
+		known = append(known, role.Name)
+	}
+	return checkNames(names, known, "role")
+}
+
+// knownPermissions deduplicates and sorts permission names and checks that every permission exists
+func (s *AuthService) knownPermissions(names []string) ([]string, error) {
+	permissions, err := s.roleRepo.ListPermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		known = append(known, permission.Name)
+	}
+	return checkNames(names, known, "permission")
+}
+
+// checkNames returns the sorted, deduplicated names or an error naming the first unknown one
+func checkNames(names, known []string, kind string) ([]string, error) {
+	result := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("%w: unknown %s %q", ErrInvalidArgument, kind, name)
+		}
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	slices.Sort(result)
+	return result, nil
+}
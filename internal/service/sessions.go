@@ -0,0 +1,66 @@
+package service
+
+import (
+	"log"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// ListSessions returns the active sessions of a user.
+// current is the family of the caller's token, which is flagged in the list.
+func (s *AuthService) ListSessions(userID uuid.UUID, current string) ([]models.SessionInfo, error) {
+	sessions, err := s.tokenRepo.ListActiveFamilies(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]models.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, models.SessionInfo{
+			ID:         session.ID.String(),
+			ClientID:   session.ClientID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID.String() == current,
+		})
+	}
+
+	return infos, nil
+}
+
+// RevokeSession signs out one of the user's sessions.
+// Its refresh token stops working and its access tokens fail validation right away.
+func (s *AuthService) RevokeSession(userID, sessionID uuid.UUID, info RequestInfo) error {
+	revoked, err := s.tokenRepo.RevokeUserFamily(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	log.Printf("Session %s of user %s was revoked", sessionID, userID)
+	event := subjectAuditEvent(models.AuditSessionRevoke, models.AuditSuccess, userID)
+	event.Reason = sessionID.String()
+	s.recordAudit(event, info)
+	return nil
+}
+
+// RevokeOtherSessions signs out every session of the user except the current one.
+// A token without a session, which this service does not issue, signs out all of them.
+func (s *AuthService) RevokeOtherSessions(userID uuid.UUID, current string, info RequestInfo) error {
+	currentFamily, _ := uuid.Parse(current)
+	if err := s.tokenRepo.RevokeUserFamilies(userID, currentFamily); err != nil {
+		return err
+	}
+
+	log.Printf("Other sessions of user %s were revoked", userID)
+	event := subjectAuditEvent(models.AuditSessionRevoke, models.AuditSuccess, userID)
+	event.Reason = "others"
+	s.recordAudit(event, info)
+	return nil
+}
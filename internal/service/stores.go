@@ -0,0 +1,105 @@
+package service
+
+import (
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// The service depends on these subsets of the repositories, so tests can replace them with fakes
+
+// userStore is implemented by repository.UserRepository
+type userStore interface {
+	CreateUser(email, passwordHash string) (uuid.UUID, error)
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(id uuid.UUID) (*models.User, error)
+	ListLoginHistory(userID uuid.UUID, limit int) ([]models.LoginHistoryEntry, error)
+	RecordLogin(entry *models.LoginHistoryEntry) error
+	ReplacePasswordHash(userID uuid.UUID, oldHash, newHash string) (bool, error)
+	SetEmailVerified(userID uuid.UUID, email string) (bool, error)
+	UpdateEmail(userID uuid.UUID, email string) error
+	UpdateName(userID uuid.UUID, name string) error
+	UpdatePassword(userID uuid.UUID, passwordHash string) error
+	UserExists(email string) (bool, error)
+}
+
+// tokenStore is implemented by repository.TokenRepository
+type tokenStore interface {
+	CreateFamily(session *models.Session) (uuid.UUID, error)
+	DeletePasswordResetTokens(userID uuid.UUID) error
+	GetAuthorizationCode(codeHash string) (*models.AuthorizationCode, error)
+	GetPasswordResetTokenUser(tokenHash string) (uuid.UUID, error)
+	GetRefreshToken(id uuid.UUID) (*models.RefreshToken, error)
+	ListActiveFamilies(userID uuid.UUID) ([]models.Session, error)
+	RevokeAccessToken(tokenID string, userID uuid.UUID, expiresAt time.Time) error
+	RevokeFamily(familyID uuid.UUID) error
+	RevokeUserFamilies(userID, keep uuid.UUID) error
+	RevokeUserFamily(userID, familyID uuid.UUID) (bool, error)
+	RotateRefreshToken(oldID uuid.UUID, next *models.RefreshToken) (bool, error)
+	SaveAuthorizationCode(code *models.AuthorizationCode) error
+	SavePasswordResetToken(userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	SaveRefreshToken(token *models.RefreshToken) error
+	SetAuthorizationCodeFamily(codeHash string, familyID uuid.UUID) error
+	TouchFamily(familyID uuid.UUID, ip, userAgent string) error
+	UseAuthorizationCode(codeHash string) (bool, error)
+	UsePasswordResetToken(tokenHash string) (uuid.UUID, error)
+}
+
+// clientStore is implemented by repository.ClientRepository
+type clientStore interface {
+	CreateClient(client *models.OAuthClient) error
+	DeleteClient(id string) (bool, error)
+	GetClient(id string) (*models.OAuthClient, error)
+	ListClients() ([]models.OAuthClient, error)
+}
+
+// mfaStore is implemented by repository.MFARepository
+type mfaStore interface {
+	ConsumeWebAuthnSession(id uuid.UUID) (*models.WebAuthnSession, error)
+	CreateWebAuthnCredential(credential *models.WebAuthnCredential) (bool, error)
+	CreateWebAuthnSession(userID *uuid.UUID, ceremony, challenge string, expiresAt time.Time) (uuid.UUID, error)
+	DeleteRecoveryCodes(userID uuid.UUID) error
+	DeleteTOTP(userID uuid.UUID) error
+	DeleteWebAuthnCredential(userID, id uuid.UUID) (bool, error)
+	EnableTOTP(userID uuid.UUID) error
+	GetTOTP(userID uuid.UUID) (*models.TOTPCredential, error)
+	GetWebAuthnCredential(credentialID []byte) (*models.WebAuthnCredential, error)
+	ListUnusedRecoveryCodes(userID uuid.UUID) ([]models.RecoveryCode, error)
+	ListWebAuthnCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	MFAMethods(userID uuid.UUID) ([]string, error)
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	SaveTOTPSecret(userID uuid.UUID, secret string) (bool, error)
+	UseRecoveryCode(id uuid.UUID) (bool, error)
+	UseTOTPCounter(userID uuid.UUID, counter int64) (bool, error)
+	UseWebAuthnCredential(id uuid.UUID, signCount int64) (bool, error)
+}
+
+// loginAttemptStore is implemented by repository.LoginAttemptRepository
+type loginAttemptStore interface {
+	IsLocked(scope, key string) (bool, error)
+	Lock(scope, key string, duration time.Duration) error
+	LockedFor(account, ip string) (time.Duration, error)
+	RecordFailure(scope, key string, window time.Duration) (int, error)
+	Reset(scope, key string) error
+}
+
+// auditStore is implemented by repository.AuditRepository
+type auditStore interface {
+	ListEvents(filter models.AuditEventFilter) ([]models.AuditEvent, error)
+	Record(event *models.AuditEvent) error
+}
+
+// roleStore is implemented by repository.RoleRepository
+type roleStore interface {
+	CreatePermission(permission *models.Permission) (bool, error)
+	CreateRole(role *models.Role) (bool, error)
+	DeletePermission(name string) (bool, error)
+	DeleteRole(name string) (bool, error)
+	GetRole(name string) (*models.Role, error)
+	GetUserAccess(userID uuid.UUID) (*models.UserAccess, error)
+	ListPermissions() ([]models.Permission, error)
+	ListRoles() ([]models.Role, error)
+	SetUserRoles(userID uuid.UUID, roles []string) error
+	UpdateRole(role *models.Role) (bool, error)
+}
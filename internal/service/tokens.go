@@ -0,0 +1,206 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// Token type hints defined by RFC 7009 and RFC 7662
+const (
+	TokenHintAccessToken  = "access_token"
+	TokenHintRefreshToken = "refresh_token"
+)
+
+// IntrospectToken describes a token per RFC 7662. Unknown, expired and revoked tokens are reported as inactive.
+func (s *AuthService) IntrospectToken(token, hint string) (*models.IntrospectionResponse, error) {
+	inactive := &models.IntrospectionResponse{Active: false}
+	if token == "" {
+		return inactive, nil
+	}
+
+	// The hint only decides which type is tried first
+	if hint == TokenHintRefreshToken {
+		if response, err := s.introspectRefreshToken(token); response != nil || err != nil {
+			return response, err
+		}
+		if response := introspectAccessToken(token); response != nil {
+			return response, nil
+		}
+		return inactive, nil
+	}
+
+	if response := introspectAccessToken(token); response != nil {
+		return response, nil
+	}
+	if response, err := s.introspectRefreshToken(token); response != nil || err != nil {
+		return response, err
+	}
+	return inactive, nil
+}
+
+// RevokeToken revokes an access or refresh token per RFC 7009 on behalf of a client.
+// Revoking a refresh token revokes its whole family. Invalid tokens and tokens
+// issued to another client, including first-party tokens, are ignored.
+func (s *AuthService) RevokeToken(token, hint string, client *models.OAuthClient, info RequestInfo) error {
+	if token == "" {
+		return nil
+	}
+
+	revokeAccess := func() (bool, error) {
+		claims, err := utils.ParseTokenClaims(token)
+		if err != nil {
+			return false, nil
+		}
+		if !issuedTo(claims, client) {
+			return true, nil
+		}
+		if err := s.revokeAccessToken(claims); err != nil {
+			return true, err
+		}
+		s.recordRevokeAudit(claims, info)
+		return true, nil
+	}
+
+	revokeRefresh := func() (bool, error) {
+		claims, err := utils.ParseRefreshToken(token)
+		if err != nil {
+			return false, nil
+		}
+		familyID, err := uuid.Parse(claims.FamilyID)
+		if err != nil {
+			return false, nil
+		}
+		if !issuedTo(claims, client) {
+			return true, nil
+		}
+		if err := s.tokenRepo.RevokeFamily(familyID); err != nil {
+			return true, err
+		}
+		s.recordRevokeAudit(claims, info)
+		return true, nil
+	}
+
+	first, second := revokeAccess, revokeRefresh
+	if hint == TokenHintRefreshToken {
+		first, second = revokeRefresh, revokeAccess
+	}
+
+	if done, err := first(); done || err != nil {
+		return err
+	}
+	_, err := second()
+	return err
+}
+
+// issuedTo reports whether a token was issued to the client. First-party tokens carry no client.
+func issuedTo(claims *utils.TokenClaims, client *models.OAuthClient) bool {
+	return claims.ClientID != "" && claims.ClientID == client.ID
+}
+
+// revokeAccessToken records an access token as revoked; service tokens are recorded without a user
+func (s *AuthService) revokeAccessToken(claims *utils.TokenClaims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	userID := uuid.Nil
+	if !claims.IsService() {
+		id, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			return nil
+		}
+		userID = id
+	}
+
+	return s.tokenRepo.RevokeAccessToken(claims.ID, userID, claims.ExpiresAt.Time)
+}
+
+// recordRevokeAudit records a token revoked at the revocation endpoint; service tokens have no subject
+func (s *AuthService) recordRevokeAudit(claims *utils.TokenClaims, info RequestInfo) {
+	event := models.AuditEvent{
+		Event:        models.AuditTokenRevoke,
+		Outcome:      models.AuditSuccess,
+		SubjectEmail: claims.Email,
+		ClientID:     claims.ClientID,
+		Reason:       auditReasonOAuthRevoke,
+	}
+	if userID, err := uuid.Parse(claims.UserID); err == nil {
+		event.SubjectID = &userID
+	}
+	s.recordAudit(event, info)
+}
+
+// introspectAccessToken describes an active access token, or returns nil if it is not one
+func introspectAccessToken(token string) *models.IntrospectionResponse {
+	claims, err := utils.ParseTokenClaims(token)
+	if err != nil {
+		return nil
+	}
+
+	response := introspectionFromClaims(claims)
+	response.TokenType = "Bearer"
+	return response
+}
+
+// introspectRefreshToken describes an active refresh token, or returns nil if it is not one
+func (s *AuthService) introspectRefreshToken(token string) (*models.IntrospectionResponse, error) {
+	claims, err := utils.ParseRefreshToken(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, nil
+	}
+
+	// The server-side record decides whether the token is still usable
+	stored, err := s.tokenRepo.GetRefreshToken(tokenID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.IntrospectionResponse{Active: false}, nil
+		}
+		log.Printf("Error introspecting refresh token: %v", err)
+		return nil, err
+	}
+
+	if stored.RotatedAt != nil || stored.FamilyRevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return &models.IntrospectionResponse{Active: false}, nil
+	}
+
+	response := introspectionFromClaims(claims)
+	response.TokenType = TokenHintRefreshToken
+	return response, nil
+}
+
+// introspectionFromClaims fills the standard introspection fields from token claims
+func introspectionFromClaims(claims *utils.TokenClaims) *models.IntrospectionResponse {
+	response := &models.IntrospectionResponse{
+		Active:      true,
+		Scope:       claims.Scope,
+		ClientID:    claims.ClientID,
+		Username:    claims.Email,
+		Sub:         claims.Subject,
+		Jti:         claims.ID,
+		Role:        claims.Role,
+		SessionID:   claims.FamilyID,
+		Aud:         claims.Audience,
+		SubType:     claims.SubjectKind(),
+		AMR:         claims.AMR,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}
+	if claims.ExpiresAt != nil {
+		response.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	return response
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+func TestIssuedTo(t *testing.T) {
+	client := &models.OAuthClient{ID: "gateway"}
+
+	tests := []struct {
+		name     string
+		clientID string
+		want     bool
+	}{
+		{name: "same client", clientID: "gateway", want: true},
+		{name: "other client", clientID: "reports"},
+		{name: "first-party token", clientID: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &utils.TokenClaims{ClientID: tt.clientID}
+			if got := issuedTo(claims, client); got != tt.want {
+				t.Errorf("issuedTo(%q) = %v, want %v", tt.clientID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeTokenIgnoresTokensOfOtherClients(t *testing.T) {
+	t.Setenv("JWT_SECRET", "revoke test secret")
+
+	// The service has no token repository, so revoking anything would panic
+	s := &AuthService{}
+	client := &models.OAuthClient{ID: "gateway"}
+
+	for _, clientID := range []string{"reports", ""} {
+		pair, err := utils.GenerateTokenPair(uuid.New(), "user@example.com", "user", models.UserAccess{}, uuid.New(), utils.TokenOptions{ClientID: clientID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct{ token, hint string }{
+			{token: pair.AccessToken, hint: TokenHintAccessToken},
+			{token: pair.AccessToken, hint: TokenHintRefreshToken},
+			{token: pair.RefreshToken, hint: TokenHintRefreshToken},
+			{token: pair.RefreshToken, hint: ""},
+		} {
+			if err := s.RevokeToken(tt.token, tt.hint, client, RequestInfo{}); err != nil {
+				t.Errorf("RevokeToken of a token issued to %q with hint %q: %v", clientID, tt.hint, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// lookupCacheTTL is how long strict validation trusts a cached user or client lookup
+const lookupCacheTTL = 30 * time.Second
+
+// TokenValidation is the outcome of validating an access token
+type TokenValidation struct {
+	Valid  bool
+	Reason string
+	Claims *utils.TokenClaims
+}
+
+// ValidateToken checks an access token's signature, expiry and revocation status.
+// In strict mode it also confirms that the user still exists and still has the roles and permissions
+// in the token, or for service tokens that the client is still registered.
+func (s *AuthService) ValidateToken(token string, strict bool) *TokenValidation {
+	claims, err := utils.ParseTokenClaims(token)
+	if err != nil {
+		log.Printf("Token validation failed: %v", err)
+		return &TokenValidation{Valid: false, Reason: utils.TokenErrorReason(err)}
+	}
+
+	if strict {
+		check := s.checkUserState
+		if claims.IsService() {
+			check = s.checkClientState
+		}
+		if reason := check(claims); reason != "" {
+			return &TokenValidation{Valid: false, Reason: reason, Claims: claims}
+		}
+	}
+
+	return &TokenValidation{Valid: true, Claims: claims}
+}
+
+// checkUserState compares the token's subject with the stored user and returns a reason if they disagree
+func (s *AuthService) checkUserState(claims *utils.TokenClaims) string {
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return utils.ReasonMalformed
+	}
+
+	user, err := s.userCache.get(userID)
+	if err != nil {
+		log.Printf("Error loading user for strict validation: %v", err)
+		return utils.ReasonInternalError
+	}
+
+	if user == nil {
+		return utils.ReasonUserNotFound
+	}
+
+	if user.Role != claims.Role {
+		return utils.ReasonRoleChanged
+	}
+
+	// Tokens issued before users could hold several roles carry neither list and are not compared
+	if claims.Roles == nil && claims.Permissions == nil {
+		return ""
+	}
+
+	access, err := s.accessCache.get(userID)
+	if err != nil {
+		log.Printf("Error loading roles for strict validation: %v", err)
+		return utils.ReasonInternalError
+	}
+
+	if !slices.Equal(access.Roles, claims.Roles) {
+		return utils.ReasonRoleChanged
+	}
+	if !slices.Equal(access.Permissions, claims.Permissions) {
+		return utils.ReasonPermissionsChanged
+	}
+
+	return ""
+}
+
+// checkClientState confirms that the client a service token was issued to is still registered
+func (s *AuthService) checkClientState(claims *utils.TokenClaims) string {
+	client, err := s.clientCache.get(claims.ClientID)
+	if err != nil {
+		log.Printf("Error loading client for strict validation: %v", err)
+		return utils.ReasonInternalError
+	}
+
+	if client == nil {
+		return utils.ReasonClientNotFound
+	}
+
+	return ""
+}
+
+// lookupCache keeps recent repository lookups for strict token validation
+type lookupCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	load    func(K) (*V, error)
+	ttl     time.Duration
+	entries map[K]lookupCacheEntry[V]
+}
+
+// lookupCacheEntry is a cached lookup; a nil value means the record does not exist
+type lookupCacheEntry[V any] struct {
+	value     *V
+	expiresAt time.Time
+}
+
+// newLookupCache creates an empty cache that loads missing entries with load.
+// sql.ErrNoRows from load is cached as a missing record.
+func newLookupCache[K comparable, V any](load func(K) (*V, error), ttl time.Duration) *lookupCache[K, V] {
+	return &lookupCache[K, V]{load: load, ttl: ttl, entries: map[K]lookupCacheEntry[V]{}}
+}
+
+// get returns the value from the cache, loading it when missing or stale
+func (c *lookupCache[K, V]) get(key K) (*V, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := c.load(key)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		value = nil
+	}
+
+	c.mu.Lock()
+	// Drop stale entries so the cache does not grow without bound
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = lookupCacheEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return value, nil
+}
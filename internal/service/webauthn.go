@@ -0,0 +1,445 @@
+package service
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/diplom/auth-service/internal/utils"
+	"github.com/google/uuid"
+)
+
+// WebAuthn ceremonies a session can be answered for
+const (
+	webAuthnCeremonyRegister = "register"
+	webAuthnCeremonyLogin    = "login"
+	webAuthnCeremonyMFA      = "mfa"
+)
+
+// webAuthnChallengeSize is the number of random bytes in a WebAuthn challenge
+const webAuthnChallengeSize = 32
+
+// maxCredentialNameLength matches the size of the name column
+const maxCredentialNameLength = 255
+
+// defaultCredentialName is used when the user does not name a new authenticator
+const defaultCredentialName = "Passkey"
+
+// BeginWebAuthnRegistration starts registering a passkey or security key for the user
+func (s *AuthService) BeginWebAuthnRegistration(userID uuid.UUID) (*models.WebAuthnRegistrationOptions, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authenticators that already hold a credential for the user should not register again
+	existing, err := s.mfaRepo.ListWebAuthnCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, challenge, err := s.startWebAuthnSession(&user.ID, webAuthnCeremonyRegister)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []models.CredentialParameter{}
+	for _, alg := range utils.COSEAlgorithms() {
+		params = append(params, models.CredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return &models.WebAuthnRegistrationOptions{
+		SessionID: sessionID,
+		PublicKey: models.PublicKeyCredentialCreationOptions{
+			Challenge: challenge,
+			RP:        models.RelyingParty{ID: utils.WebAuthnRPID(), Name: utils.WebAuthnRPName()},
+			User: models.WebAuthnUser{
+				ID:          utils.EncodeWebAuthnBytes(user.ID[:]),
+				Name:        user.Email,
+				DisplayName: user.Email,
+			},
+			PubKeyCredParams:   params,
+			Timeout:            utils.WebAuthnTimeout.Milliseconds(),
+			ExcludeCredentials: credentialDescriptors(existing),
+			AuthenticatorSelection: models.AuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: "preferred",
+			},
+			Attestation: "none",
+		},
+	}, nil
+}
+
+// FinishWebAuthnRegistration verifies the browser's response and stores the new credential.
+// A user's first second factor also gets a set of recovery codes.
+func (s *AuthService) FinishWebAuthnRegistration(userID uuid.UUID, req *models.WebAuthnRegisterRequest) (*models.WebAuthnRegisterResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultCredentialName
+	}
+	if len(name) > maxCredentialNameLength {
+		return nil, fmt.Errorf("%w: name must be at most %d characters", ErrInvalidArgument, maxCredentialNameLength)
+	}
+
+	if req.Credential.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unsupported credential type", ErrInvalidArgument)
+	}
+	clientDataJSON, err := utils.DecodeWebAuthnBytes(req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid clientDataJSON", ErrInvalidArgument)
+	}
+	attestationObject, err := utils.DecodeWebAuthnBytes(req.Credential.Response.AttestationObject)
+	if err != nil || len(attestationObject) == 0 {
+		return nil, fmt.Errorf("%w: invalid attestationObject", ErrInvalidArgument)
+	}
+
+	session, err := s.consumeWebAuthnSession(req.SessionID, webAuthnCeremonyRegister, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := utils.VerifyWebAuthnRegistration(clientDataJSON, attestationObject, session.Challenge, false)
+	if err != nil {
+		log.Printf("WebAuthn registration rejected for user %s: %v", userID, err)
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	credential := &models.WebAuthnCredential{
+		UserID:       userID,
+		CredentialID: authData.CredentialID,
+		PublicKey:    authData.PublicKey,
+		SignCount:    int64(authData.SignCount),
+		AAGUID:       authData.AAGUID,
+		Name:         name,
+		Transports:   req.Credential.Response.Transports,
+	}
+	if credential.Transports == nil {
+		credential.Transports = []string{}
+	}
+
+	created, err := s.mfaRepo.CreateWebAuthnCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrCredentialExists
+	}
+
+	recoveryCodes, err := s.ensureRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnRegisterResponse{
+		WebAuthnCredentialInfo: credentialInfo(*credential),
+		RecoveryCodes:          recoveryCodes,
+	}, nil
+}
+
+// ListWebAuthnCredentials returns the authenticators registered by the user
+func (s *AuthService) ListWebAuthnCredentials(userID uuid.UUID) ([]models.WebAuthnCredentialInfo, error) {
+	credentials, err := s.mfaRepo.ListWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]models.WebAuthnCredentialInfo, 0, len(credentials))
+	for _, credential := range credentials {
+		infos = append(infos, credentialInfo(credential))
+	}
+
+	return infos, nil
+}
+
+// DeleteWebAuthnCredential removes one of the user's authenticators
+func (s *AuthService) DeleteWebAuthnCredential(userID, id uuid.UUID) error {
+	deleted, err := s.mfaRepo.DeleteWebAuthnCredential(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCredentialNotFound
+	}
+
+	return s.dropRecoveryCodesWithoutMFA(userID)
+}
+
+// BeginWebAuthnLogin starts a passwordless login with a passkey.
+// The browser offers the user's discoverable credentials, so no account is named up front.
+func (s *AuthService) BeginWebAuthnLogin() (*models.WebAuthnLoginOptions, error) {
+	sessionID, challenge, err := s.startWebAuthnSession(nil, webAuthnCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnLoginOptions{
+		SessionID: sessionID,
+		PublicKey: models.PublicKeyCredentialRequestOptions{
+			Challenge:        challenge,
+			Timeout:          utils.WebAuthnTimeout.Milliseconds(),
+			RPID:             utils.WebAuthnRPID(),
+			AllowCredentials: []models.CredentialDescriptor{},
+			UserVerification: "required",
+		},
+	}, nil
+}
+
+// FinishWebAuthnLogin verifies a passkey assertion and issues a token pair
+func (s *AuthService) FinishWebAuthnLogin(sessionID string, credential *models.PublicKeyCredential, info RequestInfo) (*AuthResult, error) {
+	user, amr, err := s.authenticatePasskey(sessionID, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := s.issueTokens(user, utils.TokenOptions{AMR: amr}, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	return &AuthResult{User: user, Tokens: tokenPair}, nil
+}
+
+// BeginWebAuthnMFA starts a security key check as the second step of a login started by Login
+func (s *AuthService) BeginWebAuthnMFA(mfaToken string) (*models.WebAuthnLoginOptions, error) {
+	user, _, err := s.mfaTokenUser(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.mfaRepo.ListWebAuthnCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, ErrMFANotEnrolled
+	}
+
+	sessionID, challenge, err := s.startWebAuthnSession(&user.ID, webAuthnCeremonyMFA)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnLoginOptions{
+		SessionID: sessionID,
+		PublicKey: models.PublicKeyCredentialRequestOptions{
+			Challenge:        challenge,
+			Timeout:          utils.WebAuthnTimeout.Milliseconds(),
+			RPID:             utils.WebAuthnRPID(),
+			AllowCredentials: credentialDescriptors(credentials),
+			UserVerification: "discouraged",
+		},
+	}, nil
+}
+
+// CompleteWebAuthnMFALogin finishes a login started by Login with a security key assertion
+func (s *AuthService) CompleteWebAuthnMFALogin(mfaToken, sessionID string, credential *models.PublicKeyCredential, info RequestInfo) (*AuthResult, error) {
+	user, amr, err := s.verifyWebAuthnSecondFactor(mfaToken, sessionID, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := s.issueTokens(user, utils.TokenOptions{AMR: amr}, info)
+	if err != nil {
+		log.Printf("Error generating tokens: %v", err)
+		return nil, err
+	}
+
+	return &AuthResult{User: user, Tokens: tokenPair}, nil
+}
+
+// authenticatePasskey verifies a passwordless login and returns the user with the factors used.
+// Passkey logins require user verification, so the passkey alone counts as two factors.
+func (s *AuthService) authenticatePasskey(sessionID string, credential *models.PublicKeyCredential) (*models.User, []string, error) {
+	session, err := s.consumeWebAuthnSession(sessionID, webAuthnCeremonyLogin, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := s.verifyWebAuthnAssertion(session, credential, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.GetUser(stored.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, nil, ErrInvalidWebAuthnResponse
+		}
+		return nil, nil, err
+	}
+
+	if err := checkEmailVerified(user); err != nil {
+		return nil, nil, err
+	}
+
+	return user, []string{utils.AMRHardwareKey, utils.AMRMFA}, nil
+}
+
+// verifyWebAuthnSecondFactor checks an MFA challenge token and a security key assertion.
+// It returns the user and the factors used for the whole login.
+func (s *AuthService) verifyWebAuthnSecondFactor(mfaToken, sessionID string, credential *models.PublicKeyCredential) (*models.User, []string, error) {
+	user, claims, err := s.mfaTokenUser(mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := s.consumeWebAuthnSession(sessionID, webAuthnCeremonyMFA, &user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := s.verifyWebAuthnAssertion(session, credential, false); err != nil {
+		return nil, nil, err
+	}
+
+	amr := append(append([]string{}, claims.AMR...), utils.AMRHardwareKey, utils.AMRMFA)
+	return user, amr, nil
+}
+
+// startWebAuthnSession stores a fresh challenge for a ceremony
+func (s *AuthService) startWebAuthnSession(userID *uuid.UUID, ceremony string) (string, string, error) {
+	challenge, err := utils.RandomToken(webAuthnChallengeSize)
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionID, err := s.mfaRepo.CreateWebAuthnSession(userID, ceremony, challenge, time.Now().Add(utils.WebAuthnTimeout))
+	if err != nil {
+		return "", "", err
+	}
+
+	return sessionID.String(), challenge, nil
+}
+
+// consumeWebAuthnSession loads and removes a ceremony, checking it was started for this purpose and user
+func (s *AuthService) consumeWebAuthnSession(sessionID, ceremony string, userID *uuid.UUID) (*models.WebAuthnSession, error) {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.mfaRepo.ConsumeWebAuthnSession(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if session.Ceremony != ceremony || time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if (session.UserID == nil) != (userID == nil) || (userID != nil && *session.UserID != *userID) {
+		return nil, ErrInvalidToken
+	}
+
+	return session, nil
+}
+
+// verifyWebAuthnAssertion checks an assertion against the stored credential and its signature counter
+func (s *AuthService) verifyWebAuthnAssertion(session *models.WebAuthnSession, credential *models.PublicKeyCredential, requireUV bool) (*models.WebAuthnCredential, error) {
+	if credential.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unsupported credential type", ErrInvalidArgument)
+	}
+
+	rawID := credential.RawID
+	if rawID == "" {
+		rawID = credential.ID
+	}
+	credentialID, err := utils.DecodeWebAuthnBytes(rawID)
+	if err != nil || len(credentialID) == 0 {
+		return nil, fmt.Errorf("%w: invalid credential ID", ErrInvalidArgument)
+	}
+
+	response := credential.Response
+	clientDataJSON, err := utils.DecodeWebAuthnBytes(response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid clientDataJSON", ErrInvalidArgument)
+	}
+	authenticatorData, err := utils.DecodeWebAuthnBytes(response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid authenticatorData", ErrInvalidArgument)
+	}
+	signature, err := utils.DecodeWebAuthnBytes(response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature", ErrInvalidArgument)
+	}
+	userHandle, err := utils.DecodeWebAuthnBytes(response.UserHandle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid userHandle", ErrInvalidArgument)
+	}
+
+	stored, err := s.mfaRepo.GetWebAuthnCredential(credentialID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidWebAuthnResponse
+		}
+		return nil, err
+	}
+
+	// The credential must belong to the user the ceremony was started for, and to the user the authenticator names
+	if session.UserID != nil && *session.UserID != stored.UserID {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, stored.UserID[:]) {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	authData, err := utils.VerifyWebAuthnAssertion(clientDataJSON, authenticatorData, signature, stored.PublicKey, session.Challenge, requireUV)
+	if err != nil {
+		log.Printf("WebAuthn assertion rejected for credential %s: %v", stored.ID, err)
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	if !utils.WebAuthnCounterValid(stored.SignCount, authData.SignCount) {
+		log.Printf("Signature counter of WebAuthn credential %s did not increase, the authenticator may be cloned", stored.ID)
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	// The update checks the counter again, so that only one of two concurrent assertions passes
+	used, err := s.mfaRepo.UseWebAuthnCredential(stored.ID, int64(authData.SignCount))
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		log.Printf("Signature counter of WebAuthn credential %s changed concurrently", stored.ID)
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	return stored, nil
+}
+
+// credentialDescriptors lists credentials in the form used by WebAuthn options
+func credentialDescriptors(credentials []models.WebAuthnCredential) []models.CredentialDescriptor {
+	descriptors := make([]models.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, models.CredentialDescriptor{
+			Type:       "public-key",
+			ID:         utils.EncodeWebAuthnBytes(credential.CredentialID),
+			Transports: credential.Transports,
+		})
+	}
+	return descriptors
+}
+
+// credentialInfo describes a stored credential without its key material
+func credentialInfo(credential models.WebAuthnCredential) models.WebAuthnCredentialInfo {
+	transports := []string(credential.Transports)
+	if transports == nil {
+		transports = []string{}
+	}
+
+	return models.WebAuthnCredentialInfo{
+		ID:         credential.ID.String(),
+		Name:       credential.Name,
+		Transports: transports,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"errors"
+	"math"
+)
+
+// CBOR major types (RFC 8949 section 3.1)
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+// cborMaxDepth limits nesting so that hostile input cannot exhaust the stack
+const cborMaxDepth = 16
+
+var errCBORMalformed = errors.New("malformed CBOR data")
+
+// cborDecoder reads the subset of CBOR used by WebAuthn attestation objects and COSE keys.
+// Integers decode to int64, byte strings to []byte, text to string, arrays to []interface{}
+// and maps to map[interface{}]interface{} with int64 or string keys.
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// decodeCBOR decodes a single CBOR item and returns it with the number of bytes it used
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+// decode reads the next item
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, errCBORMalformed
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		if arg > math.MaxInt64 {
+			return nil, errCBORMalformed
+		}
+		return int64(arg), nil
+	case cborNegative:
+		if arg > math.MaxInt64 {
+			return nil, errCBORMalformed
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		raw, err := d.read(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(raw), nil
+		}
+		return append([]byte(nil), raw...), nil
+	case cborArray:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORMalformed
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORMalformed
+		}
+		items := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, errCBORMalformed
+			}
+			if _, exists := items[key]; exists {
+				return nil, errCBORMalformed
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items[key] = value
+		}
+		return items, nil
+	case cborTag:
+		// Tags carry no meaning for WebAuthn data, so only the tagged value is kept
+		return d.decode(depth + 1)
+	default:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		}
+		return nil, errCBORMalformed
+	}
+}
+
+// head reads the initial byte of an item and its argument.
+// Indefinite lengths and floating point values are not used by WebAuthn and are rejected.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, errCBORMalformed
+	}
+	initial := d.data[d.pos]
+	d.pos++
+
+	major, info := initial>>5, initial&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		raw, err := d.read(1 << (info - 24))
+		if err != nil {
+			return 0, 0, err
+		}
+		var arg uint64
+		for _, b := range raw {
+			arg = arg<<8 | uint64(b)
+		}
+		if major == cborSimple {
+			return 0, 0, errCBORMalformed
+		}
+		return major, arg, nil
+	}
+
+	return 0, 0, errCBORMalformed
+}
+
+// read returns the next n bytes
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORMalformed
+	}
+	raw := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return raw, nil
+}
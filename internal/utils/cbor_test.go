@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    interface{}
+		wantLen int
+		wantErr bool
+	}{
+		{name: "small unsigned", data: []byte{0x17}, want: int64(23), wantLen: 1},
+		{name: "two-byte unsigned", data: []byte{0x19, 0x01, 0x00}, want: int64(256), wantLen: 3},
+		{name: "negative", data: []byte{0x38, 0x63}, want: int64(-100), wantLen: 2},
+		{name: "bytes", data: []byte{0x42, 0x01, 0x02}, want: []byte{1, 2}, wantLen: 3},
+		{name: "text", data: []byte{0x63, 'f', 'm', 't'}, want: "fmt", wantLen: 4},
+		{name: "array", data: []byte{0x82, 0x01, 0x20}, want: []interface{}{int64(1), int64(-1)}, wantLen: 3},
+		{name: "map", data: []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf5}, want: map[interface{}]interface{}{int64(1): int64(2), "a": true}, wantLen: 6},
+		{name: "tag", data: []byte{0xc0, 0x60}, want: "", wantLen: 2},
+		{name: "null", data: []byte{0xf6}, want: nil, wantLen: 1},
+		{name: "stops after one item", data: []byte{0x01, 0x02}, want: int64(1), wantLen: 1},
+		{name: "empty", data: []byte{}, wantErr: true},
+		{name: "truncated bytes", data: []byte{0x43, 0x01}, wantErr: true},
+		{name: "truncated argument", data: []byte{0x19, 0x01}, wantErr: true},
+		{name: "unsigned overflow", data: []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "indefinite length", data: []byte{0x5f, 0x41, 0x00, 0xff}, wantErr: true},
+		{name: "float", data: []byte{0xf9, 0x3c, 0x00}, wantErr: true},
+		{name: "array longer than input", data: []byte{0x9a, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "byte string map key", data: []byte{0xa1, 0x41, 0x00, 0x01}, wantErr: true},
+		{name: "duplicate map key", data: []byte{0xa2, 0x01, 0x01, 0x01, 0x02}, wantErr: true},
+		{name: "nested too deep", data: append(bytes.Repeat([]byte{0x81}, cborMaxDepth+1), 0x00), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n, err := decodeCBOR(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeCBOR(%x) = %#v, want error", tt.data, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != tt.wantLen {
+				t.Errorf("length = %d, want %d", n, tt.wantLen)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR(%x) = %#v, want %#v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	// Seed with the attestation objects and public keys of the recorded WebAuthn fixtures
+	for _, alg := range webAuthnAlgorithms {
+		fixture, err := readWebAuthnFixture(alg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, value := range []string{fixture.Registration.AttestationObject, fixture.Registration.PublicKey} {
+			data, err := DecodeWebAuthnBytes(value)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(data)
+		}
+	}
+	f.Add([]byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf5})
+	f.Add([]byte{0x9a, 0xff, 0xff, 0xff, 0xff})
+	f.Add(append(bytes.Repeat([]byte{0x81}, cborMaxDepth), 0x00))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, n, err := decodeCBOR(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("decodeCBOR used %d of %d bytes", n, len(data))
+		}
+
+		// The item must decode the same way without the bytes that follow it
+		again, m, err := decodeCBOR(data[:n])
+		if err != nil || m != n || !reflect.DeepEqual(again, value) {
+			t.Fatalf("decoding the item alone gave %#v, %d, %v; want %#v, %d", again, m, err, value, n)
+		}
+
+		// Decoded keys must never panic the COSE parser
+		ParseCOSEKey(data[:n])
+	})
+}
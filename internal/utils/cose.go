@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms accepted for WebAuthn credentials (RFC 9053), in order of preference
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// COSE key parameters (RFC 9052 section 7 and RFC 9053 section 7)
+const (
+	coseKeyType  = 1
+	coseKeyAlg   = 3
+	coseKeyCurve = -1
+	coseKeyX     = -2
+	coseKeyY     = -3
+	coseKeyRSAN  = -1
+	coseKeyRSAE  = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// COSEAlgorithms lists the algorithms offered to authenticators during registration
+func COSEAlgorithms() []int {
+	return []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+}
+
+// ParseCOSEKey decodes a CBOR-encoded COSE public key and returns the key with its algorithm
+func ParseCOSEKey(data []byte) (crypto.PublicKey, int, error) {
+	decoded, n, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n != len(data) {
+		return nil, 0, errors.New("unexpected data after COSE key")
+	}
+
+	return coseKeyFromMap(decoded)
+}
+
+// coseKeyFromMap converts a decoded COSE key map into a public key
+func coseKeyFromMap(decoded interface{}) (crypto.PublicKey, int, error) {
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("COSE key is not a map")
+	}
+
+	kty, _ := params[int64(coseKeyType)].(int64)
+	alg, _ := params[int64(coseKeyAlg)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == COSEAlgES256:
+		crv, _ := params[int64(coseKeyCurve)].(int64)
+		x, _ := params[int64(coseKeyX)].([]byte)
+		y, _ := params[int64(coseKeyY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid P-256 COSE key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, errors.New("COSE key point is not on the curve")
+		}
+		return key, COSEAlgES256, nil
+
+	case kty == coseKeyTypeOKP && alg == COSEAlgEdDSA:
+		crv, _ := params[int64(coseKeyCurve)].(int64)
+		x, _ := params[int64(coseKeyX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid Ed25519 COSE key")
+		}
+		return ed25519.PublicKey(x), COSEAlgEdDSA, nil
+
+	case kty == coseKeyTypeRSA && alg == COSEAlgRS256:
+		n, _ := params[int64(coseKeyRSAN)].([]byte)
+		e, _ := params[int64(coseKeyRSAE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA COSE key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		if exponent < 3 {
+			return nil, 0, errors.New("invalid RSA COSE key exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, COSEAlgRS256, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+}
+
+// verifyCOSESignature checks a WebAuthn signature made with a key parsed by ParseCOSEKey
+func verifyCOSESignature(key crypto.PublicKey, alg int, message, signature []byte) error {
+	switch alg {
+	case COSEAlgES256:
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm")
+		}
+		// WebAuthn ECDSA signatures are ASN.1 DER encoded
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(public, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case COSEAlgEdDSA:
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm")
+		}
+		if !ed25519.Verify(public, message, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case COSEAlgRS256:
+		public, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match algorithm")
+		}
+		digest := sha256.Sum256(message)
+		if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported COSE algorithm %d", alg)
+}
@@ -4,80 +4,200 @@ import (
 	"errors"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/diplom/auth-service/internal/models"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// AccessTokenTTL is the lifetime of an access token
+const AccessTokenTTL = 1 * time.Hour
+
+// RefreshTokenTTL is the lifetime of a refresh token
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// ClientTokenTTL is the lifetime of a machine token issued to an OAuth client
+const ClientTokenTTL = 15 * time.Minute
+
+// Token types carried in the token_type claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeID      = "id"
+	TokenTypeMFA     = "mfa"
+	// TokenTypeEmailVerification marks the tokens in email verification links
+	TokenTypeEmailVerification = "email_verification"
+)
+
+// Authentication method references (RFC 8176) carried in the amr claim
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRMFA         = "mfa"
+	// AMRRecovery is not registered in RFC 8176; it flags logins completed with a recovery code
+	AMRRecovery = "recovery"
+)
+
+// Subject types carried in the sub_type claim of access tokens
+const (
+	SubjectTypeUser    = "user"
+	SubjectTypeService = "service"
+)
+
 // TokenClaims represents the JWT claims structure
 type TokenClaims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID      string           `json:"user_id,omitempty"`
+	Email       string           `json:"email,omitempty"`
+	Role        string           `json:"role"`
+	Scope       string           `json:"scope,omitempty"`
+	TokenType   string           `json:"token_type,omitempty"`
+	FamilyID    string           `json:"fid,omitempty"`
+	ClientID    string           `json:"client_id,omitempty"`
+	AuthTime    *jwt.NumericDate `json:"auth_time,omitempty"`
+	SubjectType string           `json:"sub_type,omitempty"`
+	AMR         []string         `json:"amr,omitempty"`
+	Roles       []string         `json:"roles,omitempty"`
+	Permissions []string         `json:"permissions,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsService reports whether the token was issued to an OAuth client rather than a user
+func (c *TokenClaims) IsService() bool {
+	return c.SubjectType == SubjectTypeService
+}
+
+// HasRole reports whether the token's user holds the role.
+// Tokens issued before users could hold several roles only carry the role claim.
+func (c *TokenClaims) HasRole(role string) bool {
+	if c.Role == role {
+		return true
+	}
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectKind returns the subject type; tokens issued before the claim existed belong to users
+func (c *TokenClaims) SubjectKind() string {
+	if c.SubjectType == "" {
+		return SubjectTypeUser
+	}
+	return c.SubjectType
+}
+
+// TokenOptions carries the optional claims of an issued token pair.
+// They are copied into the refresh token so that refreshed pairs keep them.
+type TokenOptions struct {
+	Scope    string
+	ClientID string
+	AuthTime time.Time
+	AMR      []string
+}
+
+// Options returns the options the token was issued with
+func (c *TokenClaims) Options() TokenOptions {
+	opts := TokenOptions{Scope: c.Scope, ClientID: c.ClientID, AMR: c.AMR}
+	if c.AuthTime != nil {
+		opts.AuthTime = c.AuthTime.Time
+	}
+	return opts
+}
+
+// Scopes returns the space-delimited scope claim as a list
+func (c *TokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
 // TokenPair holds both access and refresh tokens
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresAt    int64  `json:"expires_at"`
+
+	// RefreshTokenID, RefreshExpiresAt and FamilyID describe the refresh token so it can be stored server-side
+	RefreshTokenID   uuid.UUID `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
+	FamilyID         uuid.UUID `json:"-"`
 }
 
-// GenerateTokenPair generates both access and refresh tokens
-func GenerateTokenPair(userID uuid.UUID, role string) (TokenPair, error) {
+// GenerateTokenPair generates both access and refresh tokens.
+// The refresh token is bound to the given token family.
+// Roles and permissions are only put into the access token; refreshing looks them up again.
+func GenerateTokenPair(userID uuid.UUID, email, role string, access models.UserAccess, familyID uuid.UUID, opts TokenOptions) (TokenPair, error) {
+	// The authentication time defaults to now for a fresh login
+	if opts.AuthTime.IsZero() {
+		opts.AuthTime = time.Now()
+	}
+
 	// Generate access token
-	accessToken, accessExpiresAt, err := generateAccessToken(userID, role)
+	accessToken, accessExpiresAt, err := generateAccessToken(userID, email, role, access, familyID, opts)
 	if err != nil {
 		return TokenPair{}, err
 	}
 
 	// Generate refresh token
-	refreshToken, err := generateRefreshToken(userID, role)
+	refreshToken, refreshTokenID, refreshExpiresAt, err := generateRefreshToken(userID, role, familyID, opts)
 	if err != nil {
 		return TokenPair{}, err
 	}
 
 	return TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    accessExpiresAt.Unix(),
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresAt:        accessExpiresAt.Unix(),
+		RefreshTokenID:   refreshTokenID,
+		RefreshExpiresAt: refreshExpiresAt,
+		FamilyID:         familyID,
 	}, nil
 }
 
 // GenerateToken is kept for backward compatibility
 func GenerateToken(userID uuid.UUID, role string) (string, error) {
-	token, _, err := generateAccessToken(userID, role)
+	token, _, err := generateAccessToken(userID, "", role, models.UserAccess{}, uuid.Nil, TokenOptions{})
 	return token, err
 }
 
-// generateAccessToken generates a new JWT access token for a user
-func generateAccessToken(userID uuid.UUID, role string) (string, time.Time, error) {
-	// Get JWT secret from environment
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", time.Time{}, errors.New("JWT_SECRET environment variable not set")
-	}
-
+// generateAccessToken generates a new JWT access token for a user.
+// When familyID is set, the token is tied to that refresh token family.
+func generateAccessToken(userID uuid.UUID, email, role string, access models.UserAccess, familyID uuid.UUID, opts TokenOptions) (string, time.Time, error) {
 	// Set expiration time (1 hour)
-	expirationTime := time.Now().Add(1 * time.Hour)
+	expirationTime := time.Now().Add(AccessTokenTTL)
 
 	// Create the JWT claims
 	claims := &TokenClaims{
-		UserID: userID.String(),
-		Role:   role,
+		UserID:      userID.String(),
+		Email:       email,
+		Role:        role,
+		Scope:       opts.Scope,
+		TokenType:   TokenTypeAccess,
+		ClientID:    opts.ClientID,
+		SubjectType: SubjectTypeUser,
+		AMR:         opts.AMR,
+		Roles:       access.Roles,
+		Permissions: access.Permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    Issuer(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   userID.String(),
 		},
 	}
+	if familyID != uuid.Nil {
+		claims.FamilyID = familyID.String()
+	}
+	if !opts.AuthTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(opts.AuthTime)
+	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	// Sign the token with the configured key
+	tokenString, err := signWithActiveKey(claims)
 	if err != nil {
 		log.Printf("Error signing token: %v", err)
 		return "", time.Time{}, err
@@ -86,8 +206,68 @@ func generateAccessToken(userID uuid.UUID, role string) (string, time.Time, erro
 	return tokenString, expirationTime, nil
 }
 
-// generateRefreshToken generates a new JWT refresh token for a user
-func generateRefreshToken(userID uuid.UUID, role string) (string, error) {
+// GenerateClientToken generates a short-lived access token for an OAuth client acting on its own behalf
+func GenerateClientToken(clientID, scope, audience string) (string, time.Time, error) {
+	expirationTime := time.Now().Add(ClientTokenTTL)
+
+	// Machine tokens have no user; the client is the subject
+	claims := &TokenClaims{
+		Scope:       scope,
+		TokenType:   TokenTypeAccess,
+		ClientID:    clientID,
+		SubjectType: SubjectTypeService,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    Issuer(),
+			Subject:   clientID,
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+
+	tokenString, err := signWithActiveKey(claims)
+	if err != nil {
+		log.Printf("Error signing client token: %v", err)
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// signWithActiveKey signs claims with the active key and names it in the kid header
+func signWithActiveKey(claims jwt.Claims) (string, error) {
+	manager, err := currentKeyManager()
+	if err != nil {
+		return "", err
+	}
+
+	key := manager.ActiveKey()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey())
+}
+
+// accessTokenKey selects the key that verifies an access token by its kid header
+func accessTokenKey(token *jwt.Token) (interface{}, error) {
+	manager, err := currentKeyManager()
+	if err != nil {
+		return nil, err
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := manager.VerificationKey(kid, token.Method.Alg())
+	if err != nil {
+		return nil, err
+	}
+
+	return key.verifyKey(), nil
+}
+
+// refreshTokenSecret returns the secret used to sign and verify refresh tokens
+func refreshTokenSecret() (string, error) {
 	// Get refresh token secret from environment
 	refreshSecret := os.Getenv("REFRESH_TOKEN_SECRET")
 	if refreshSecret == "" {
@@ -97,15 +277,35 @@ func generateRefreshToken(userID uuid.UUID, role string) (string, error) {
 			return "", errors.New("neither REFRESH_TOKEN_SECRET nor JWT_SECRET environment variables are set")
 		}
 	}
+	return refreshSecret, nil
+}
+
+// generateRefreshToken generates a new JWT refresh token for a user
+func generateRefreshToken(userID uuid.UUID, role string, familyID uuid.UUID, opts TokenOptions) (string, uuid.UUID, time.Time, error) {
+	refreshSecret, err := refreshTokenSecret()
+	if err != nil {
+		return "", uuid.Nil, time.Time{}, err
+	}
 
 	// Set expiration time (30 days)
-	expirationTime := time.Now().Add(30 * 24 * time.Hour)
+	expirationTime := time.Now().Add(RefreshTokenTTL)
+
+	// Every refresh token gets a unique ID so it can be tracked server-side
+	tokenID := uuid.New()
 
 	// Create the JWT claims
 	claims := &TokenClaims{
-		UserID: userID.String(),
-		Role:   role,
+		UserID:    userID.String(),
+		Role:      role,
+		Scope:     opts.Scope,
+		TokenType: TokenTypeRefresh,
+		FamilyID:  familyID.String(),
+		ClientID:  opts.ClientID,
+		AuthTime:  jwt.NewNumericDate(opts.AuthTime),
+		AMR:       opts.AMR,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
+			Issuer:    Issuer(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   userID.String(),
@@ -119,18 +319,44 @@ func generateRefreshToken(userID uuid.UUID, role string) (string, error) {
 	tokenString, err := token.SignedString([]byte(refreshSecret))
 	if err != nil {
 		log.Printf("Error signing refresh token: %v", err)
+		return "", uuid.Nil, time.Time{}, err
+	}
+
+	return tokenString, tokenID, expirationTime, nil
+}
+
+// ParseRefreshToken parses and validates a refresh token and returns its claims
+func ParseRefreshToken(tokenString string) (*TokenClaims, error) {
+	claims, err := parseInternalToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens must carry an ID and a family
+	if claims.TokenType != TokenTypeRefresh || claims.ID == "" || claims.FamilyID == "" {
+		return nil, errors.New("refresh token is missing required claims")
+	}
+
+	return claims, nil
+}
+
+// signInternalToken signs claims with the refresh token secret for tokens only this service reads back
+func signInternalToken(claims *TokenClaims) (string, error) {
+	refreshSecret, err := refreshTokenSecret()
+	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(refreshSecret))
 }
 
-// ParseToken parses and validates a JWT token
-func ParseToken(tokenString string) (uuid.UUID, string, error) {
-	// Get JWT secret from environment
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return uuid.Nil, "", errors.New("JWT_SECRET environment variable not set")
+// parseInternalToken parses a token signed with the refresh token secret.
+// Such tokens are only ever read back by this service.
+func parseInternalToken(tokenString string) (*TokenClaims, error) {
+	refreshSecret, err := refreshTokenSecret()
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse the token
@@ -139,22 +365,32 @@ func ParseToken(tokenString string) (uuid.UUID, string, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(jwtSecret), nil
+		return []byte(refreshSecret), nil
 	})
 
 	if err != nil {
-		return uuid.Nil, "", err
+		return nil, err
 	}
 
 	// Check if the token is valid
 	if !token.Valid {
-		return uuid.Nil, "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Extract the claims
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
-		return uuid.Nil, "", errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
+
+// ParseToken parses and validates a JWT token
+func ParseToken(tokenString string) (uuid.UUID, string, error) {
+	claims, err := ParseTokenClaims(tokenString)
+	if err != nil {
+		return uuid.Nil, "", err
 	}
 
 	// Parse user ID
@@ -165,3 +401,37 @@ func ParseToken(tokenString string) (uuid.UUID, string, error) {
 
 	return userID, claims.Role, nil
 }
+
+// ParseTokenClaims parses and validates an access token and returns its claims.
+// Tokens revoked through the configured RevocationStore are rejected.
+func ParseTokenClaims(tokenString string) (*TokenClaims, error) {
+	// Parse the token
+	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, accessTokenKey, jwt.WithIssuedAt())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the token is valid
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	// Extract the claims
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	// Refresh and ID tokens must not be accepted as access tokens
+	if claims.TokenType != "" && claims.TokenType != TokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+
+	// Check whether the token has been revoked
+	if err := checkRevocation(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
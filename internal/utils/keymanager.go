@@ -0,0 +1,461 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diplom/auth-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// retiredKeyLeeway is extra time a retired key stays valid to absorb clock skew
+const retiredKeyLeeway = time.Minute
+
+var (
+	// ErrUnknownKey is returned when a token names a key that is not known
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrKeyStoreNotConfigured is returned by key operations that need persistence
+	ErrKeyStoreNotConfigured = errors.New("key store not configured")
+	// ErrUnsupportedAlgorithm is returned when a key cannot be generated for an algorithm
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	// ErrKeyOperationNotAllowed is returned when a key is not in a state that permits the operation
+	ErrKeyOperationNotAllowed = errors.New("key operation not allowed")
+)
+
+// KeyStore persists signing keys so that every replica uses the same set
+type KeyStore interface {
+	ListSigningKeys() ([]models.SigningKey, error)
+	CreateSigningKey(key *models.SigningKey) error
+	PromoteSigningKey(id string) error
+	RetireSigningKey(id string) error
+	DeleteSigningKey(id string) error
+}
+
+// KeyManager holds the active signing key and the keys that may still verify tokens.
+// Static keys come from configuration; generated keys live in the KeyStore.
+type KeyManager struct {
+	mu     sync.RWMutex
+	store  KeyStore
+	static []*SigningKey
+	keys   map[string]*SigningKey
+	active *SigningKey
+}
+
+// keyManager is the manager consulted when signing and verifying access tokens
+var keyManager *KeyManager
+
+// SetKeyManager configures the key manager used for access tokens
+func SetKeyManager(manager *KeyManager) {
+	keyManager = manager
+}
+
+// currentKeyManager returns the configured manager, or one built from JWT_SECRET
+func currentKeyManager() (*KeyManager, error) {
+	if keyManager != nil {
+		return keyManager, nil
+	}
+
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, errors.New("JWT_SECRET environment variable not set")
+	}
+
+	manager := NewKeyManager(nil, NewHMACSigningKey([]byte(jwtSecret)))
+	if err := manager.Reload(); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// NewKeyManager creates a key manager. The first static key signs tokens
+// until a key from the store is promoted.
+func NewKeyManager(store KeyStore, static ...*SigningKey) *KeyManager {
+	for _, key := range static {
+		key.Static = true
+	}
+	return &KeyManager{store: store, static: static, keys: map[string]*SigningKey{}}
+}
+
+// Reload refreshes the key set from the store and drops retired keys whose tokens have expired
+func (m *KeyManager) Reload() error {
+	keys := map[string]*SigningKey{}
+	var active *SigningKey
+
+	if m.store != nil {
+		stored, err := m.store.ListSigningKeys()
+		if err != nil {
+			return err
+		}
+
+		for _, record := range stored {
+			key, err := decodeStoredKey(record)
+			if err != nil {
+				log.Printf("Skipping signing key %s: %v", record.ID, err)
+				continue
+			}
+
+			// Tokens signed with this key have all expired
+			if key.expired(time.Now()) {
+				if err := m.store.DeleteSigningKey(key.ID); err != nil {
+					log.Printf("Error deleting expired signing key %s: %v", key.ID, err)
+				}
+				continue
+			}
+
+			keys[key.ID] = key
+			if key.Status == KeyStatusActive {
+				active = key
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Static keys sign tokens until a stored key is promoted, then verify
+	// them for one more token lifetime. Keys configured as retired never sign.
+	now := time.Now()
+	for _, static := range m.static {
+		if _, exists := keys[static.ID]; exists {
+			continue
+		}
+		key := *static
+		switch {
+		case static.Status == KeyStatusRetired:
+			// Keeps the retirement time it was configured with
+		case active == nil:
+			static.RetiredAt = nil
+			key.Status = KeyStatusActive
+			key.RetiredAt = nil
+			active = &key
+		default:
+			// The grace window starts the first time the key is replaced
+			if static.RetiredAt == nil {
+				static.RetiredAt = &now
+			}
+			key.Status = KeyStatusRetired
+			key.RetiredAt = static.RetiredAt
+		}
+		if key.expired(now) {
+			continue
+		}
+		keys[key.ID] = &key
+	}
+
+	if active == nil {
+		return errors.New("no active signing key available")
+	}
+
+	m.keys = keys
+	m.active = active
+
+	return nil
+}
+
+// ActiveKey returns the key that signs new tokens
+func (m *KeyManager) ActiveKey() *SigningKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active
+}
+
+// VerificationKey returns the key identified by kid if it may verify a token signed with alg
+func (m *KeyManager) VerificationKey(kid, alg string) (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Tokens issued before kid headers were introduced can only come from static keys
+	if kid == "" {
+		for _, static := range m.static {
+			key, ok := m.keys[static.ID]
+			if ok && key.Method.Alg() == alg && !key.expired(time.Now()) {
+				return key, nil
+			}
+		}
+		return nil, ErrUnknownKey
+	}
+
+	key, ok := m.keys[kid]
+	if !ok || key.expired(time.Now()) {
+		return nil, ErrUnknownKey
+	}
+
+	if key.Method.Alg() != alg {
+		return nil, errors.New("token algorithm does not match signing key")
+	}
+
+	return key, nil
+}
+
+// Keys returns all known keys, the active one first
+func (m *KeyManager) Keys() []*SigningKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Status != keys[j].Status {
+			return keys[i].Status == KeyStatusActive
+		}
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// JWKS returns the public keys of every asymmetric key that may verify tokens
+func (m *KeyManager) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range m.Keys() {
+		if key.IsSymmetric() {
+			continue
+		}
+
+		jwk, err := publicJWK(key.Public())
+		if err != nil {
+			continue
+		}
+		jwk.Use = "sig"
+		jwk.Alg = key.Method.Alg()
+		jwk.Kid = key.ID
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+// GenerateKey creates a new key that is published for verification but does not sign yet.
+// An empty algorithm reuses the algorithm of the active key.
+func (m *KeyManager) GenerateKey(alg string) (*SigningKey, error) {
+	if m.store == nil {
+		return nil, ErrKeyStoreNotConfigured
+	}
+
+	if alg == "" {
+		alg = m.ActiveKey().Method.Alg()
+	}
+
+	key, err := generateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	material, err := encodeKeyMaterial(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := EncryptSecret(material)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.store.CreateSigningKey(&models.SigningKey{
+		ID:          key.ID,
+		Algorithm:   alg,
+		KeyMaterial: sealed,
+		Status:      string(KeyStatusNext),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Generated %s signing key %s", alg, key.ID)
+
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// PromoteKey makes a stored key the active signing key and retires the previous one
+func (m *KeyManager) PromoteKey(kid string) error {
+	if m.store == nil {
+		return ErrKeyStoreNotConfigured
+	}
+
+	key, err := m.storedKey(kid)
+	if err != nil {
+		return err
+	}
+
+	if key.Status != KeyStatusNext {
+		return fmt.Errorf("%w: only keys with status %q can be promoted", ErrKeyOperationNotAllowed, KeyStatusNext)
+	}
+
+	if err := m.store.PromoteSigningKey(kid); err != nil {
+		return err
+	}
+
+	log.Printf("Promoted signing key %s", kid)
+	return m.Reload()
+}
+
+// RetireKey stops a stored key from verifying new tokens once its grace window passes
+func (m *KeyManager) RetireKey(kid string) error {
+	if m.store == nil {
+		return ErrKeyStoreNotConfigured
+	}
+
+	key, err := m.storedKey(kid)
+	if err != nil {
+		return err
+	}
+
+	if key.Status == KeyStatusActive {
+		return fmt.Errorf("%w: the active key cannot be retired, promote another key first", ErrKeyOperationNotAllowed)
+	}
+
+	if err := m.store.RetireSigningKey(kid); err != nil {
+		return err
+	}
+
+	log.Printf("Retired signing key %s", kid)
+	return m.Reload()
+}
+
+// RotateKey generates a new key, promotes it and retires the previous active key
+func (m *KeyManager) RotateKey(alg string) (*SigningKey, error) {
+	key, err := m.GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.PromoteKey(key.ID); err != nil {
+		return nil, err
+	}
+
+	return m.VerificationKey(key.ID, key.Method.Alg())
+}
+
+// storedKey looks up a key that can be managed through the key store
+func (m *KeyManager) storedKey(kid string) (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if key.Static {
+		return nil, fmt.Errorf("%w: static keys are managed through configuration", ErrKeyOperationNotAllowed)
+	}
+
+	return key, nil
+}
+
+// ExpiresAt returns when a retired key stops verifying tokens, or nil if it does not expire
+func (k *SigningKey) ExpiresAt() *time.Time {
+	if k.Status != KeyStatusRetired || k.RetiredAt == nil {
+		return nil
+	}
+	expiresAt := k.RetiredAt.Add(AccessTokenTTL + retiredKeyLeeway)
+	return &expiresAt
+}
+
+// expired reports whether every token signed with a retired key has expired
+func (k *SigningKey) expired(now time.Time) bool {
+	expiresAt := k.ExpiresAt()
+	return expiresAt != nil && now.After(*expiresAt)
+}
+
+// generateSigningKey creates fresh key material for the given algorithm
+func generateSigningKey(alg string) (*SigningKey, error) {
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACSigningKey(secret), nil
+	case jwt.SigningMethodRS256.Alg():
+		private, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(private)
+	case jwt.SigningMethodES256.Alg():
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(private)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(private)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// encodeKeyMaterial serialises a key for the key store
+func encodeKeyMaterial(key *SigningKey) ([]byte, error) {
+	if key.IsSymmetric() {
+		return key.Secret, nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// decodeStoredKey restores a key read from the key store
+func decodeStoredKey(record models.SigningKey) (*SigningKey, error) {
+	material, err := DecryptSecret(record.KeyMaterial)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *SigningKey
+	if record.Algorithm == jwt.SigningMethodHS256.Alg() {
+		key = NewHMACSigningKey(material)
+	} else {
+		key, err = ParseSigningKey(material)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key.ID != record.ID {
+		return nil, errors.New("key ID does not match key material")
+	}
+
+	key.Status = KeyStatus(record.Status)
+	key.CreatedAt = record.CreatedAt
+	key.RetiredAt = record.RetiredAt
+	return key, nil
+}
+
+// PublicJWKS returns the public keys that verify access tokens.
+// The set is empty when tokens are signed with shared secrets only.
+func PublicJWKS() JWKS {
+	manager, err := currentKeyManager()
+	if err != nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return manager.JWKS()
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeyManagerRetiredStaticKey(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signing, err := NewSigningKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs256 := jwt.SigningMethodHS256.Alg()
+	tests := []struct {
+		name      string
+		retiredAt time.Time
+		wantErr   bool
+	}{
+		{name: "within the token lifetime", retiredAt: time.Now()},
+		{name: "after the token lifetime", retiredAt: time.Now().Add(-AccessTokenTTL - retiredKeyLeeway - time.Second), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legacy := NewHMACSigningKey([]byte("legacy secret"))
+			legacy.Status = KeyStatusRetired
+			legacy.RetiredAt = &tt.retiredAt
+
+			manager := NewKeyManager(nil, signing, legacy)
+			if err := manager.Reload(); err != nil {
+				t.Fatal(err)
+			}
+
+			if active := manager.ActiveKey(); active.ID != signing.ID {
+				t.Fatalf("active key = %s, want %s", active.ID, signing.ID)
+			}
+
+			for _, kid := range []string{"", legacy.ID} {
+				_, err := manager.VerificationKey(kid, hs256)
+				if tt.wantErr && !errors.Is(err, ErrUnknownKey) {
+					t.Errorf("VerificationKey(%q) error = %v, want %v", kid, err, ErrUnknownKey)
+				}
+				if !tt.wantErr && err != nil {
+					t.Errorf("VerificationKey(%q) error = %v", kid, err)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyManagerOnlyStaticKeySigns(t *testing.T) {
+	key := NewHMACSigningKey([]byte("only secret"))
+	manager := NewKeyManager(nil, key)
+	if err := manager.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if active := manager.ActiveKey(); active.ID != key.ID || active.Status != KeyStatusActive {
+		t.Fatalf("active key = %s (%s), want %s (%s)", active.ID, active.Status, key.ID, KeyStatusActive)
+	}
+	if _, err := manager.VerificationKey("", jwt.SigningMethodHS256.Alg()); err != nil {
+		t.Errorf("kid-less token rejected: %v", err)
+	}
+	if _, err := manager.VerificationKey("", jwt.SigningMethodRS256.Alg()); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("VerificationKey with another algorithm error = %v, want %v", err, ErrUnknownKey)
+	}
+}
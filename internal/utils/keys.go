@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing
+const minRSAKeyBits = 2048
+
+// KeyStatus describes what a signing key may be used for
+type KeyStatus string
+
+const (
+	// KeyStatusActive is the single key that signs new tokens
+	KeyStatusActive KeyStatus = "active"
+	// KeyStatusNext is a published key that only verifies tokens until it is promoted
+	KeyStatusNext KeyStatus = "next"
+	// KeyStatusRetired is a former key that verifies tokens until they have expired
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+// SigningKey is a key used to sign and verify access tokens.
+// Asymmetric keys carry a private key, HMAC keys carry a shared secret.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	Secret    []byte
+	Status    KeyStatus
+	CreatedAt time.Time
+	RetiredAt *time.Time
+
+	// Static keys come from configuration rather than the key store
+	Static bool
+}
+
+// Public returns the public half of an asymmetric key, or nil for HMAC keys
+func (k *SigningKey) Public() crypto.PublicKey {
+	if k.Private == nil {
+		return nil
+	}
+	return k.Private.Public()
+}
+
+// IsSymmetric reports whether the key is an HMAC secret
+func (k *SigningKey) IsSymmetric() bool {
+	return k.Private == nil
+}
+
+// signKey returns the key material passed to the JWT signer
+func (k *SigningKey) signKey() interface{} {
+	if k.IsSymmetric() {
+		return k.Secret
+	}
+	return k.Private
+}
+
+// verifyKey returns the key material passed to the JWT verifier
+func (k *SigningKey) verifyKey() interface{} {
+	if k.IsSymmetric() {
+		return k.Secret
+	}
+	return k.Public()
+}
+
+// NewHMACSigningKey wraps a shared secret as an HS256 key.
+// The kid is derived from a hash of the secret so it is stable across restarts.
+func NewHMACSigningKey(secret []byte) *SigningKey {
+	sum := sha256.Sum256(append([]byte("kid:"), secret...))
+	return &SigningKey{
+		ID:        "hs-" + base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method:    jwt.SigningMethodHS256,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+}
+
+// LoadSigningKey reads a PEM encoded RSA, ECDSA or Ed25519 private key from a file
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSigningKey(data)
+}
+
+// ParseSigningKey parses a PEM encoded private key in PKCS#1, SEC 1 or PKCS#8 form
+func ParseSigningKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot be used for signing")
+	}
+
+	return NewSigningKey(signer)
+}
+
+// NewSigningKey wraps a private key, picking the JWT algorithm from its type
+func NewSigningKey(private crypto.Signer) (*SigningKey, error) {
+	var method jwt.SigningMethod
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, errors.New("unsupported elliptic curve")
+		}
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+
+	jwk, err := publicJWK(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := jwkThumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{ID: kid, Method: method, Private: private, CreatedAt: time.Now()}, nil
+}
+
+// JWK is a JSON Web Key holding a public key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// publicJWK converts a public key to its JWK representation
+func publicJWK(public crypto.PublicKey) (JWK, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   encode(key.N.Bytes()),
+			E:   encode(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// Uncompressed point: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		return JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   encode(point[1 : 1+size]),
+			Y:   encode(point[1+size:]),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   encode(key),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint of a key, used as its kid
+func jwkThumbprint(jwk JWK) (string, error) {
+	// Only the required members, in lexicographic order
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Defaults of the login lockout policy
+const (
+	defaultLockoutThreshold   = 10
+	defaultIPLockoutThreshold = 100
+	defaultLockoutDuration    = 15 * time.Minute
+)
+
+// lockoutBackoffAfter is the number of failures in a row an account gets before backoff starts
+const lockoutBackoffAfter = 3
+
+// LockoutPolicy describes how failed logins slow down and lock out further attempts
+type LockoutPolicy struct {
+	// Threshold is the number of failures in a row that locks an account
+	Threshold int
+	// IPThreshold is the number of failures in a row that locks a source address
+	IPThreshold int
+	// Duration is how long a lock lasts; failures older than this are forgotten
+	Duration time.Duration
+}
+
+// LoginLockoutPolicy returns the policy configured with LOGIN_LOCKOUT_THRESHOLD,
+// LOGIN_LOCKOUT_IP_THRESHOLD and LOGIN_LOCKOUT_DURATION
+func LoginLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		Threshold:   envInt("LOGIN_LOCKOUT_THRESHOLD", defaultLockoutThreshold),
+		IPThreshold: envInt("LOGIN_LOCKOUT_IP_THRESHOLD", defaultIPLockoutThreshold),
+		Duration:    envDuration("LOGIN_LOCKOUT_DURATION", defaultLockoutDuration),
+	}
+}
+
+// AccountDelay returns how long an account must wait after the given number of failures in a row.
+// The delay doubles from one second after the first few failures and becomes a full lock at the threshold.
+func (p LockoutPolicy) AccountDelay(failures int) time.Duration {
+	if failures >= p.Threshold {
+		return p.Duration
+	}
+	if failures < lockoutBackoffAfter {
+		return 0
+	}
+
+	delay := time.Second
+	for i := lockoutBackoffAfter; i < failures && delay < p.Duration; i++ {
+		delay *= 2
+	}
+	if delay > p.Duration {
+		return p.Duration
+	}
+	return delay
+}
+
+// IPDelay returns how long a source address must wait after the given number of failures in a row.
+// Addresses get no backoff because many users may share one behind a NAT.
+func (p LockoutPolicy) IPDelay(failures int) time.Duration {
+	if failures >= p.IPThreshold {
+		return p.Duration
+	}
+	return 0
+}
+
+// defaultLoginHistoryRetentionDays is how long login history is kept unless configured otherwise
+const defaultLoginHistoryRetentionDays = 90
+
+// LoginHistoryRetention returns how long login history entries are kept, from LOGIN_HISTORY_RETENTION_DAYS
+func LoginHistoryRetention() time.Duration {
+	return time.Duration(envInt("LOGIN_HISTORY_RETENTION_DAYS", defaultLoginHistoryRetentionDays)) * 24 * time.Hour
+}
+
+// envInt reads a positive integer from the environment
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// envDuration reads a positive duration such as "15m" from the environment
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MFATokenTTL is how long a user has to complete the second login step
+const MFATokenTTL = 5 * time.Minute
+
+// MFATokenMaxAttempts is the number of wrong codes after which an MFA challenge token stops being accepted
+const MFATokenMaxAttempts = 5
+
+// Recovery codes are ten lowercase base32 characters, shown in two groups of five
+const (
+	recoveryCodeLength   = 10
+	recoveryCodeGroup    = 5
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+// GenerateMFAToken issues the challenge token returned by the first login step.
+// It records the factors already used and proves nothing on its own.
+func GenerateMFAToken(userID uuid.UUID, amr []string) (string, time.Time, error) {
+	expirationTime := time.Now().Add(MFATokenTTL)
+
+	claims := &TokenClaims{
+		UserID:    userID.String(),
+		TokenType: TokenTypeMFA,
+		AMR:       amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   userID.String(),
+		},
+	}
+
+	// Challenge tokens are only read back by this service, so they use the internal secret
+	tokenString, err := signInternalToken(claims)
+	if err != nil {
+		log.Printf("Error signing MFA token: %v", err)
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// ParseMFAToken parses and validates an MFA challenge token and returns its claims
+func ParseMFAToken(tokenString string) (*TokenClaims, error) {
+	claims, err := parseInternalToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != TokenTypeMFA {
+		return nil, errors.New("not an MFA challenge token")
+	}
+
+	return claims, nil
+}
+
+// GenerateRecoveryCode returns a new single-use recovery code such as "k7dqa-3mxzp"
+func GenerateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	// 256 is a multiple of the alphabet size, so the characters are uniformly distributed
+	code := make([]byte, 0, recoveryCodeLength+1)
+	for i, b := range raw {
+		if i == recoveryCodeGroup {
+			code = append(code, '-')
+		}
+		code = append(code, recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+
+	return string(code), nil
+}
+
+// NormalizeRecoveryCode strips the separators users may type and reports whether
+// the input has the shape of a recovery code rather than a one-time password
+func NormalizeRecoveryCode(code string) (string, bool) {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != recoveryCodeLength {
+		return "", false
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(recoveryCodeAlphabet, c) {
+			return "", false
+		}
+	}
+	return code, true
+}
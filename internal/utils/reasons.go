@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Reason codes explaining why a token was rejected
+const (
+	ReasonMalformed          = "malformed"
+	ReasonExpired            = "expired"
+	ReasonNotYetValid        = "not_yet_valid"
+	ReasonInvalidSignature   = "invalid_signature"
+	ReasonUnknownKey         = "unknown_key"
+	ReasonRevoked            = "revoked"
+	ReasonWrongTokenType     = "wrong_token_type"
+	ReasonUserNotFound       = "user_not_found"
+	ReasonRoleChanged        = "role_changed"
+	ReasonPermissionsChanged = "permissions_changed"
+	ReasonClientNotFound     = "client_not_found"
+	ReasonInternalError      = "internal_error"
+)
+
+// ErrWrongTokenType is returned when a token of another type is presented as an access token
+var ErrWrongTokenType = errors.New("token of this type cannot be used as access token")
+
+// TokenErrorReason maps a token parsing error to a reason code
+func TokenErrorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenRevoked):
+		return ReasonRevoked
+	case errors.Is(err, ErrUnknownKey):
+		return ReasonUnknownKey
+	case errors.Is(err, ErrWrongTokenType):
+		return ReasonWrongTokenType
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ReasonExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ReasonNotYetValid
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ReasonInvalidSignature
+	case errors.Is(err, jwt.ErrTokenMalformed), errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return ReasonMalformed
+	default:
+		return ReasonInternalError
+	}
+}
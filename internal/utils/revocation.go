@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"log"
+)
+
+// ErrTokenRevoked is returned when a token has been revoked before its expiry
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationStore reports whether an access token has been revoked,
+// either directly by its ID or through its refresh token family
+type RevocationStore interface {
+	IsTokenRevoked(tokenID, familyID string) (bool, error)
+}
+
+var revocationStore RevocationStore
+
+// SetRevocationStore configures the store consulted when parsing access tokens
+func SetRevocationStore(store RevocationStore) {
+	revocationStore = store
+}
+
+// checkRevocation returns ErrTokenRevoked if the token was revoked
+func checkRevocation(claims *TokenClaims) error {
+	if revocationStore == nil {
+		return nil
+	}
+
+	revoked, err := revocationStore.IsTokenRevoked(claims.ID, claims.FamilyID)
+	if err != nil {
+		// Fail closed: a token whose status is unknown is not accepted
+		log.Printf("Error checking token revocation: %v", err)
+		return errors.New("unable to verify token revocation status")
+	}
+
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
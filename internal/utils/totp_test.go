@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Key is the SHA-1 secret of the RFC 6238 Appendix B test vectors
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Appendix B lists eight-digit codes; six-digit codes are their last six digits
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{time: 59, want: "287082"},
+		{time: 1111111109, want: "081804"},
+		{time: 1111111111, want: "050471"},
+		{time: 1234567890, want: "005924"},
+		{time: 2000000000, want: "279037"},
+		{time: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Key, tt.time/totpPeriod); got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	codeAt := func(offset int64) string {
+		return totpCode(rfc6238Key, current+offset)
+	}
+
+	tests := []struct {
+		name        string
+		secret      string
+		code        string
+		lastCounter int64
+		wantCounter int64
+		wantOK      bool
+	}{
+		{name: "current period", code: codeAt(0), wantCounter: current, wantOK: true},
+		{name: "RFC 6238 vector", code: "050471", wantCounter: current, wantOK: true},
+		{name: "surrounding spaces", code: " " + codeAt(0) + " ", wantCounter: current, wantOK: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: codeAt(0), wantCounter: current, wantOK: true},
+		{name: "previous period at the window edge", code: codeAt(-totpSkew), wantCounter: current - totpSkew, wantOK: true},
+		{name: "next period at the window edge", code: codeAt(totpSkew), wantCounter: current + totpSkew, wantOK: true},
+		{name: "before the window", code: codeAt(-totpSkew - 1)},
+		{name: "after the window", code: codeAt(totpSkew + 1)},
+		{name: "replay of the current period", code: codeAt(0), lastCounter: current},
+		{name: "replay of an earlier period", code: codeAt(-1), lastCounter: current},
+		{name: "later period after a used one", code: codeAt(1), lastCounter: current, wantCounter: current + 1, wantOK: true},
+		{name: "wrong code", code: "000000"},
+		{name: "too short", code: codeAt(0)[:5]},
+		{name: "eight digits", code: "07081804"},
+		{name: "invalid secret", secret: "not base32!", code: codeAt(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.secret == "" {
+				tt.secret = secret
+			}
+
+			counter, ok := ValidateTOTP(tt.secret, tt.code, now, tt.lastCounter)
+			if ok != tt.wantOK || counter != tt.wantCounter {
+				t.Errorf("ValidateTOTP(%q) = %d, %v; want %d, %v", tt.code, counter, ok, tt.wantCounter, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPPeriodBoundaries(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	start := time.Unix(1111111110, 0) // first second of a period
+	counter := start.Unix() / totpPeriod
+	code := totpCode(rfc6238Key, counter)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		wantOK bool
+	}{
+		{name: "last second of the period before the previous one", now: start.Add(-totpPeriod*time.Second - time.Second)},
+		{name: "first second of the previous period", now: start.Add(-totpPeriod * time.Second), wantOK: true},
+		{name: "first second of the period", now: start, wantOK: true},
+		{name: "last second of the next period", now: start.Add(2*totpPeriod*time.Second - time.Second), wantOK: true},
+		{name: "first second of the period after the next one", now: start.Add(2 * totpPeriod * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateTOTP(secret, code, tt.now, 0)
+			if ok != tt.wantOK {
+				t.Fatalf("ValidateTOTP at %d = %v, want %v", tt.now.Unix(), ok, tt.wantOK)
+			}
+			if ok && got != counter {
+				t.Errorf("counter = %d, want %d", got, counter)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import "strings"
+
+// maxDeviceNameLength caps device names supplied by clients
+const maxDeviceNameLength = 100
+
+// userAgentBrowsers are checked in order, because most browsers also name the engines they derive from
+var userAgentBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+// userAgentSystems are checked in order, because iOS and Android user agents also mention other systems
+var userAgentSystems = []struct{ token, name string }{
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// DeviceName returns a readable name for the device of a session.
+// A name chosen by the client wins; otherwise one is derived from the user agent, such as "Firefox on Windows".
+func DeviceName(name, userAgent string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		if runes := []rune(name); len(runes) > maxDeviceNameLength {
+			name = string(runes[:maxDeviceNameLength])
+		}
+		return name
+	}
+
+	var browser, system string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range userAgentSystems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+
+	// Other clients, such as grpc-go/1.58.2 or curl/8.4.0, name themselves in the first product token
+	if product, _, _ := strings.Cut(userAgent, " "); product != "" {
+		name, _, _ := strings.Cut(product, "/")
+		return name
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// WebAuthnTimeout is how long the browser and the server wait for a WebAuthn ceremony to complete
+const WebAuthnTimeout = 5 * time.Minute
+
+// WebAuthn client data types (W3C Web Authentication, section 5.8.1)
+const (
+	WebAuthnCeremonyCreate = "webauthn.create"
+	WebAuthnCeremonyGet    = "webauthn.get"
+)
+
+// defaultWebAuthnRPName is shown by browsers when WEBAUTHN_RP_NAME is not set
+const defaultWebAuthnRPName = "Auth Service"
+
+// Authenticator data flags (W3C Web Authentication, section 6.1)
+const (
+	authDataUserPresent   = 0x01
+	authDataUserVerified  = 0x04
+	authDataAttestedData  = 0x40
+	authDataExtensionData = 0x80
+)
+
+// maxCredentialIDLength is the largest credential ID authenticators may return
+const maxCredentialIDLength = 1023
+
+// AuthenticatorData is the parsed authenticator data of a registration or assertion
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Attested credential data, only present during registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// UserPresent reports whether the authenticator tested for user presence
+func (a *AuthenticatorData) UserPresent() bool {
+	return a.Flags&authDataUserPresent != 0
+}
+
+// UserVerified reports whether the authenticator verified the user with a PIN or biometrics
+func (a *AuthenticatorData) UserVerified() bool {
+	return a.Flags&authDataUserVerified != 0
+}
+
+// webAuthnClientData is the part of the client data JSON checked by the relying party
+type webAuthnClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// WebAuthnRPID returns the relying party ID that credentials are scoped to.
+// It defaults to the host name of the issuer.
+func WebAuthnRPID() string {
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		return rpID
+	}
+	issuer, err := url.Parse(Issuer())
+	if err != nil {
+		return ""
+	}
+	return issuer.Hostname()
+}
+
+// WebAuthnRPName returns the relying party name shown by browsers during registration
+func WebAuthnRPName() string {
+	if name := os.Getenv("WEBAUTHN_RP_NAME"); name != "" {
+		return name
+	}
+	return defaultWebAuthnRPName
+}
+
+// WebAuthnOrigins returns the web origins allowed to perform WebAuthn ceremonies.
+// WEBAUTHN_ORIGINS is a comma-separated list; the origin of the issuer is used by default.
+func WebAuthnOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) > 0 {
+		return origins
+	}
+
+	issuer, err := url.Parse(Issuer())
+	if err != nil {
+		return nil
+	}
+	return []string{issuer.Scheme + "://" + issuer.Host}
+}
+
+// DecodeWebAuthnBytes decodes a base64url value sent by the browser, with or without padding
+func DecodeWebAuthnBytes(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// EncodeWebAuthnBytes encodes binary data for WebAuthn options as unpadded base64url
+func EncodeWebAuthnBytes(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// VerifyWebAuthnRegistration checks the response of navigator.credentials.create() against
+// the challenge issued for it and returns the new credential.
+// Attestation statements are not verified: registration options ask for no attestation.
+func VerifyWebAuthnRegistration(clientDataJSON, attestationObject []byte, challenge string, requireUV bool) (*AuthenticatorData, error) {
+	if err := verifyClientData(clientDataJSON, WebAuthnCeremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, n, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok || n != len(attestationObject) {
+		return nil, errors.New("invalid attestation object")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(authData, requireUV); err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, errors.New("authenticator data has no attested credential")
+	}
+
+	if _, _, err := ParseCOSEKey(authData.PublicKey); err != nil {
+		return nil, err
+	}
+
+	return authData, nil
+}
+
+// VerifyWebAuthnAssertion checks the response of navigator.credentials.get() against the
+// challenge issued for it and the stored public key of the credential
+func VerifyWebAuthnAssertion(clientDataJSON, rawAuthData, signature, publicKey []byte, challenge string, requireUV bool) (*AuthenticatorData, error) {
+	if err := verifyClientData(clientDataJSON, WebAuthnCeremonyGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(authData, requireUV); err != nil {
+		return nil, err
+	}
+
+	key, alg, err := ParseCOSEKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the authenticator data followed by the hash of the client data
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err := verifyCOSESignature(key, alg, message, signature); err != nil {
+		return nil, err
+	}
+
+	return authData, nil
+}
+
+// WebAuthnCounterValid reports whether the signature counter of an assertion may follow the stored one.
+// The counter must increase, except for authenticators that do not implement it and always send zero.
+func WebAuthnCounterValid(stored int64, received uint32) bool {
+	return int64(received) > stored || (stored == 0 && received == 0)
+}
+
+// verifyClientData checks the ceremony type, challenge and origin recorded by the browser
+func verifyClientData(raw []byte, ceremony, challenge string) error {
+	var clientData webAuthnClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+
+	if clientData.Type != ceremony {
+		return fmt.Errorf("unexpected client data type %q", clientData.Type)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(clientData.Challenge, "=")), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	}
+
+	for _, origin := range WebAuthnOrigins() {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", clientData.Origin)
+}
+
+// checkAuthenticatorData checks the relying party and the user presence and verification flags
+func checkAuthenticatorData(authData *AuthenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(WebAuthnRPID()))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rpIDHash[:]) != 1 {
+		return errors.New("relying party ID mismatch")
+	}
+	if !authData.UserPresent() {
+		return errors.New("user presence was not tested")
+	}
+	if requireUV && !authData.UserVerified() {
+		return errors.New("user was not verified")
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses the binary authenticator data structure
+func parseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&authDataAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > maxCredentialIDLength || idLength > len(rest) {
+			return nil, errors.New("invalid credential ID length")
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The public key is a CBOR item whose length is only known after decoding it
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		authData.PublicKey = rest[:n]
+		rest = rest[n:]
+	}
+
+	if authData.Flags&authDataExtensionData != 0 {
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension data: %w", err)
+		}
+		rest = rest[n:]
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("unexpected data after authenticator data")
+	}
+
+	return authData, nil
+}
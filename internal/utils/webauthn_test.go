@@ -0,0 +1,329 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// webAuthnFixture is a registration and an assertion of one credential, recorded from a
+// software authenticator for the relying party "localhost" and the origin "http://localhost:8080"
+type webAuthnFixture struct {
+	RPID         string `json:"rp_id"`
+	Origin       string `json:"origin"`
+	Registration struct {
+		Challenge         string `json:"challenge"`
+		ClientDataJSON    string `json:"client_data_json"`
+		AttestationObject string `json:"attestation_object"`
+		CredentialID      string `json:"credential_id"`
+		PublicKey         string `json:"public_key"`
+	} `json:"registration"`
+	Assertion struct {
+		Challenge         string `json:"challenge"`
+		ClientDataJSON    string `json:"client_data_json"`
+		AuthenticatorData string `json:"authenticator_data"`
+		Signature         string `json:"signature"`
+		SignCount         uint32 `json:"sign_count"`
+	} `json:"assertion"`
+}
+
+// webAuthnAlgorithms names the recorded fixtures in testdata
+var webAuthnAlgorithms = []string{"es256", "rs256"}
+
+// readWebAuthnFixture reads a recorded fixture from testdata
+func readWebAuthnFixture(alg string) (*webAuthnFixture, error) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "webauthn_"+alg+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var fixture webAuthnFixture
+	if err := json.Unmarshal(raw, &fixture); err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// loadWebAuthnFixture reads a recorded fixture and points the relying party settings at it
+func loadWebAuthnFixture(t *testing.T, alg string) *webAuthnFixture {
+	t.Helper()
+
+	fixture, err := readWebAuthnFixture(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("WEBAUTHN_RP_ID", fixture.RPID)
+	t.Setenv("WEBAUTHN_ORIGINS", fixture.Origin)
+	return fixture
+}
+
+// mustDecode decodes a base64url fixture value
+func mustDecode(t *testing.T, value string) []byte {
+	t.Helper()
+
+	data, err := DecodeWebAuthnBytes(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// attestationObject encodes a "none" attestation object around the authenticator data
+func attestationObject(authData []byte) []byte {
+	out := []byte{0xa3, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0}
+	out = append(out, 0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x59)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(authData)))
+	return append(out, authData...)
+}
+
+// registrationAuthData extracts the authenticator data from the recorded attestation object
+func registrationAuthData(t *testing.T, fixture *webAuthnFixture) []byte {
+	t.Helper()
+
+	decoded, _, err := decodeCBOR(mustDecode(t, fixture.Registration.AttestationObject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData, _ := decoded.(map[interface{}]interface{})["authData"].([]byte)
+	return authData
+}
+
+// withFlags returns a copy of the authenticator data with different flags
+func withFlags(authData []byte, flags byte) []byte {
+	out := append([]byte{}, authData...)
+	out[32] = flags
+	return out
+}
+
+func TestParseAuthenticatorData(t *testing.T) {
+	for _, alg := range webAuthnAlgorithms {
+		fixture := loadWebAuthnFixture(t, alg)
+		registration := registrationAuthData(t, fixture)
+		assertion := mustDecode(t, fixture.Assertion.AuthenticatorData)
+		rpIDHash := sha256.Sum256([]byte(fixture.RPID))
+
+		tests := []struct {
+			name         string
+			data         []byte
+			wantErr      string
+			wantCount    uint32
+			wantAttested bool
+		}{
+			{name: "registration", data: registration, wantAttested: true},
+			{name: "assertion", data: assertion, wantCount: fixture.Assertion.SignCount},
+			{name: "too short", data: assertion[:36], wantErr: "too short"},
+			{name: "trailing data", data: append(append([]byte{}, assertion...), 0), wantErr: "unexpected data"},
+			{name: "attested data missing", data: withFlags(assertion, authDataUserPresent|authDataAttestedData), wantErr: "attested credential data is too short"},
+			{name: "attested data truncated", data: registration[:len(registration)-1], wantErr: "invalid credential public key"},
+			{name: "empty credential ID", data: append(append(append([]byte{}, registration[:53]...), 0, 0), registration[55:]...), wantErr: "invalid credential ID length"},
+			{name: "credential ID past the end", data: append(append(append([]byte{}, registration[:53]...), 0xff, 0xff), registration[55:]...), wantErr: "invalid credential ID length"},
+			{name: "extension data missing", data: withFlags(assertion, authDataUserPresent|authDataExtensionData), wantErr: "invalid extension data"},
+			{name: "extension data", data: append(withFlags(assertion, authDataUserPresent|authDataExtensionData), 0xa0), wantCount: fixture.Assertion.SignCount},
+		}
+
+		for _, tt := range tests {
+			t.Run(alg+"/"+tt.name, func(t *testing.T) {
+				authData, err := parseAuthenticatorData(tt.data)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("error = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+					t.Errorf("RPIDHash = %x, want %x", authData.RPIDHash, rpIDHash)
+				}
+				if authData.SignCount != tt.wantCount {
+					t.Errorf("SignCount = %d, want %d", authData.SignCount, tt.wantCount)
+				}
+				if !authData.UserPresent() {
+					t.Error("UserPresent = false")
+				}
+				if !tt.wantAttested {
+					if authData.CredentialID != nil {
+						t.Errorf("CredentialID = %x, want none", authData.CredentialID)
+					}
+					return
+				}
+				if want := mustDecode(t, fixture.Registration.CredentialID); !bytes.Equal(authData.CredentialID, want) {
+					t.Errorf("CredentialID = %x, want %x", authData.CredentialID, want)
+				}
+				if want := mustDecode(t, fixture.Registration.PublicKey); !bytes.Equal(authData.PublicKey, want) {
+					t.Errorf("PublicKey = %x, want %x", authData.PublicKey, want)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyWebAuthnRegistration(t *testing.T) {
+	for _, alg := range webAuthnAlgorithms {
+		fixture := loadWebAuthnFixture(t, alg)
+		clientData := mustDecode(t, fixture.Registration.ClientDataJSON)
+		attestation := mustDecode(t, fixture.Registration.AttestationObject)
+		authData := registrationAuthData(t, fixture)
+
+		tests := []struct {
+			name        string
+			clientData  []byte
+			attestation []byte
+			challenge   string
+			rpID        string
+			origins     string
+			requireUV   bool
+			wantErr     string
+		}{
+			{name: "valid", requireUV: true},
+			{name: "wrong origin", origins: "https://evil.example", wantErr: "origin"},
+			{name: "wrong rpIdHash", rpID: "evil.example", wantErr: "relying party ID mismatch"},
+			{name: "wrong challenge", challenge: EncodeWebAuthnBytes([]byte("another challenge")), wantErr: "challenge mismatch"},
+			{name: "assertion client data", clientData: mustDecode(t, fixture.Assertion.ClientDataJSON), wantErr: "unexpected client data type"},
+			{name: "user presence missing", attestation: attestationObject(withFlags(authData, authDataUserVerified|authDataAttestedData)), wantErr: "user presence"},
+			{name: "user verification missing", attestation: attestationObject(withFlags(authData, authDataUserPresent|authDataAttestedData)), requireUV: true, wantErr: "not verified"},
+			{name: "no attested credential", attestation: attestationObject(mustDecode(t, fixture.Assertion.AuthenticatorData)), wantErr: "no attested credential"},
+			{name: "trailing data", attestation: append(append([]byte{}, attestation...), 0), wantErr: "invalid attestation object"},
+		}
+
+		for _, tt := range tests {
+			t.Run(alg+"/"+tt.name, func(t *testing.T) {
+				if tt.rpID != "" {
+					t.Setenv("WEBAUTHN_RP_ID", tt.rpID)
+				}
+				if tt.origins != "" {
+					t.Setenv("WEBAUTHN_ORIGINS", tt.origins)
+				}
+				if tt.clientData == nil {
+					tt.clientData = clientData
+				}
+				if tt.attestation == nil {
+					tt.attestation = attestation
+				}
+				if tt.challenge == "" {
+					tt.challenge = fixture.Registration.Challenge
+				}
+
+				result, err := VerifyWebAuthnRegistration(tt.clientData, tt.attestation, tt.challenge, tt.requireUV)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("error = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if want := mustDecode(t, fixture.Registration.CredentialID); !bytes.Equal(result.CredentialID, want) {
+					t.Errorf("CredentialID = %x, want %x", result.CredentialID, want)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyWebAuthnAssertion(t *testing.T) {
+	for _, alg := range webAuthnAlgorithms {
+		fixture := loadWebAuthnFixture(t, alg)
+		clientData := mustDecode(t, fixture.Assertion.ClientDataJSON)
+		authData := mustDecode(t, fixture.Assertion.AuthenticatorData)
+		signature := mustDecode(t, fixture.Assertion.Signature)
+		publicKey := mustDecode(t, fixture.Registration.PublicKey)
+
+		tamperedSignature := append([]byte{}, signature...)
+		tamperedSignature[len(tamperedSignature)-1] ^= 0x01
+		laterCount := append([]byte{}, authData...)
+		binary.BigEndian.PutUint32(laterCount[33:37], fixture.Assertion.SignCount+1)
+
+		tests := []struct {
+			name       string
+			clientData []byte
+			authData   []byte
+			signature  []byte
+			challenge  string
+			rpID       string
+			origins    string
+			requireUV  bool
+			wantErr    string
+		}{
+			{name: "valid", requireUV: true},
+			{name: "wrong origin", origins: "https://evil.example", wantErr: "origin"},
+			{name: "one of several origins", origins: "https://app.example, " + fixture.Origin},
+			{name: "wrong rpIdHash", rpID: "evil.example", wantErr: "relying party ID mismatch"},
+			{name: "wrong challenge", challenge: EncodeWebAuthnBytes([]byte("another challenge")), wantErr: "challenge mismatch"},
+			{name: "registration client data", clientData: mustDecode(t, fixture.Registration.ClientDataJSON), wantErr: "unexpected client data type"},
+			{name: "user presence missing", authData: withFlags(authData, authDataUserVerified), wantErr: "user presence"},
+			{name: "user verification missing", authData: withFlags(authData, authDataUserPresent), requireUV: true, wantErr: "not verified"},
+			{name: "signed data changed", authData: laterCount, wantErr: "invalid signature"},
+			{name: "tampered signature", signature: tamperedSignature, wantErr: "invalid signature"},
+		}
+
+		for _, tt := range tests {
+			t.Run(alg+"/"+tt.name, func(t *testing.T) {
+				if tt.rpID != "" {
+					t.Setenv("WEBAUTHN_RP_ID", tt.rpID)
+				}
+				if tt.origins != "" {
+					t.Setenv("WEBAUTHN_ORIGINS", tt.origins)
+				}
+				if tt.clientData == nil {
+					tt.clientData = clientData
+				}
+				if tt.authData == nil {
+					tt.authData = authData
+				}
+				if tt.signature == nil {
+					tt.signature = signature
+				}
+				if tt.challenge == "" {
+					tt.challenge = fixture.Assertion.Challenge
+				}
+
+				result, err := VerifyWebAuthnAssertion(tt.clientData, tt.authData, tt.signature, publicKey, tt.challenge, tt.requireUV)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("error = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if result.SignCount != fixture.Assertion.SignCount {
+					t.Errorf("SignCount = %d, want %d", result.SignCount, fixture.Assertion.SignCount)
+				}
+			})
+		}
+	}
+}
+
+func TestWebAuthnCounterValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   int64
+		received uint32
+		want     bool
+	}{
+		{name: "increased", stored: 6, received: 7, want: true},
+		{name: "first use", stored: 0, received: 1, want: true},
+		{name: "no counter support", stored: 0, received: 0, want: true},
+		{name: "unchanged", stored: 7, received: 7, want: false},
+		{name: "decreased", stored: 7, received: 6, want: false},
+		{name: "reset to zero", stored: 7, received: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WebAuthnCounterValid(tt.stored, tt.received); got != tt.want {
+				t.Errorf("WebAuthnCounterValid(%d, %d) = %v, want %v", tt.stored, tt.received, got, tt.want)
+			}
+		})
+	}
+}